@@ -3,144 +3,719 @@ package agent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go-agent/llm"
+	"go-agent/llm/jsonextract"
 	"go-agent/metadata"
+	"go-agent/prompt"
+	"go-agent/tools/evaluation"
+	"go-agent/tools/grammar"
 	"go-agent/tools/toolstore"
+	"sort"
 	"strings"
-	"text/template"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const promptTemplate = `You are a Go software engineer. Your task is to help users call mathematical functions in Go. 
-Below are the available functions and their documentation. Respond to user requests in JSON format using the following template:
+// LLMEngine generates the reply to a prompt as a stream of tokens. A failure
+// during the generation is reported by the stream's Err, not by closing the
+// token channel early.
+type LLMEngine interface {
+	GenerateTokens(ctx context.Context, prompt string) (*llm.Stream, error)
+}
 
-{
-  "function": "<function_name>",
-  "arguments": [<arg1>, <arg2>, ...]
+// ConstrainedEngine is implemented by engines that support constrained
+// sampling. When the agent's engine implements it, function calls are
+// generated under a grammar derived from the ToolStore, so the model can
+// only name registered tools and pass arguments of the right arity and types.
+type ConstrainedEngine interface {
+	GenerateConstrained(ctx context.Context, prompt string, g grammar.Grammar) (*llm.Stream, error)
 }
 
-Here are the functions and their documentation:
-{{.Tools}}
+// Stopper is implemented by engines that can stop a generation in progress,
+// given the ID of its stream.
+type Stopper interface {
+	StopGeneration(ctx context.Context, id llm.GenerationID) error
+}
 
-User Request: {{.UserRequest}}`
+var (
+	// ErrUnknownFunction is returned when the LLM names a function that is not in the ToolStore.
+	ErrUnknownFunction = errors.New("function not found in tool store")
+	// ErrInvalidResponse is returned when the LLM reply cannot be decoded into a function call.
+	ErrInvalidResponse = errors.New("error decoding LLM response")
+	// ErrGeneration is returned when the engine fails while generating the reply.
+	ErrGeneration = errors.New("LLM generation failed")
+	// ErrNoTool is returned by Execute when the LLM finds that no tool applies to the request.
+	ErrNoTool = errors.New("no tool applies to the request")
+)
 
-type LLMEngine interface {
-	GenerateTokens(ctx context.Context, prompt string) (<-chan string, error)
+type FunctionCall struct {
+	Function  string `json:"function"`         // Function name (e.g., "Divide"); empty when the LLM declined
+	Arguments []any  `json:"arguments"`        // Function arguments (e.g., [4, 2])
+	Answer    string `json:"answer,omitempty"` // Reply to the user when no tool applies
+
+	// Repairs lists the fixes applied to the LLM reply to obtain valid JSON.
+	// They are set by the agent, never taken from the reply.
+	Repairs []jsonextract.Repair `json:"repairs,omitempty"`
 }
 
-type FunctionCall struct {
-	Function  string `json:"function"`  // Function name (e.g., "Divide")
-	Arguments []any  `json:"arguments"` // Function arguments (e.g., [4, 2])
+// Declined reports whether the LLM replied {"function": null, ...} because
+// none of the tools applies to the request.
+func (c *FunctionCall) Declined() bool {
+	return c.Function == ""
 }
 
 type Agent struct {
 	Engine        LLMEngine
-	Prompt        string
+	Prompt        string               // Template for the call prompt, rendered with prompt.Data
 	FunctionStore *toolstore.ToolStore // Map of function names to their documentation prompts
+
+	// Persona opens the call prompt, telling the LLM who it is.
+	Persona string
+
+	// Domain summarises what the tools are for. When empty it is derived
+	// from the package comments of the packages the tools come from.
+	Domain string
+
+	// ToolPrompts replaces the generated documentation of individual tools
+	// in the prompt. Each template is rendered with a prompt.Tool and keyed
+	// by tool name.
+	ToolPrompts map[string]string
+
+	// HistorySize is the number of earlier requests passed to the prompt
+	// template as History.
+	HistorySize int
+
+	// Unconstrained disables grammar-constrained decoding even when the
+	// engine supports it.
+	Unconstrained bool
+
+	// MaxRetries is the number of times the LLM is asked again when its reply
+	// cannot be decoded or names an unknown function.
+	MaxRetries int
+
+	// SynthesizeAnswer enables a second LLM pass in Respond that turns the
+	// tool result into a natural-language answer.
+	SynthesizeAnswer bool
+	AnswerPrompt     string
+	AnswerEngine     LLMEngine // Engine for the answer pass; Engine is used when nil
+
+	// Executor runs the tools, e.g. in a sandbox. Tools run in process when nil.
+	// Either way, calls observe the execution policies of the FunctionStore.
+	Executor evaluation.Executor
+
+	// Authorizer decides which callers may run which tools, with which
+	// arguments. Every call is allowed when nil.
+	Authorizer Authorizer
+
+	// Approver is asked before running a tool documented with @sideeffect
+	// or @dangerous. When nil, such tools are never run.
+	Approver Approver
+
+	// TracerProvider supplies the tracer for the agent's spans. The global
+	// provider from otel.GetTracerProvider is used when nil.
+	TracerProvider trace.TracerProvider
+
+	mu        sync.RWMutex
+	observers []subscription
+	nextID    int
+	history   []prompt.Turn
+}
+
+const defaultPersona = "You are an assistant that fulfils user requests by calling Go functions."
+
+// defaultPrompts holds the built-in call and answer templates.
+var defaultPrompts = prompt.Default()
+
+// defaultPrompt returns the text of the latest built-in template with the given name.
+func defaultPrompt(name string) string {
+	t, err := defaultPrompts.Get(name)
+	if err != nil {
+		panic(err)
+	}
+	return t.Text
 }
 
 // NewAgent creates a new Agent instance with the specified LLM engine and prompts.
 func NewAgent(engine LLMEngine, tools *toolstore.ToolStore) *Agent {
 	return &Agent{
 		Engine:        engine,
-		Prompt:        promptTemplate,
+		Prompt:        defaultPrompt(prompt.Call),
+		Persona:       defaultPersona,
 		FunctionStore: tools,
+		AnswerPrompt:  defaultPrompt(prompt.Answer),
 	}
 }
 
-func (a *Agent) Execute(userRequest string) ([]any, error) {
-	functionCall, err := a.CallLLM(userRequest)
+// UsePrompts switches the agent to the call and answer templates referenced
+// by callRef and answerRef, e.g. "call@v2" or "answer" for the latest
+// version, and to the registry's per-tool overrides.
+func (a *Agent) UsePrompts(registry *prompt.Registry, callRef, answerRef string) error {
+	call, err := registry.Get(callRef)
+	if err != nil {
+		return err
+	}
+	answer, err := registry.Get(answerRef)
 	if err != nil {
+		return err
+	}
+
+	a.Prompt = call.Text
+	a.AnswerPrompt = answer.Text
+	a.ToolPrompts = make(map[string]string)
+	for tool, t := range registry.ToolOverrides() {
+		a.ToolPrompts[tool] = t.Text
+	}
+
+	return a.Validate()
+}
+
+// Validate checks that the agent's templates parse and only refer to fields
+// of the data they are rendered with, and that every tool override names a
+// registered tool.
+func (a *Agent) Validate() error {
+	templates := map[string]string{prompt.Call: a.Prompt, prompt.Answer: a.AnswerPrompt}
+	for tool, text := range a.ToolPrompts {
+		if _, err := a.FunctionStore.GetTool(tool); err != nil {
+			return fmt.Errorf("%w: override for unknown tool %s", prompt.ErrInvalidTemplate, tool)
+		}
+		templates[prompt.ToolPrefix+tool] = text
+	}
+
+	for name, text := range templates {
+		t, err := prompt.Parse(name, text)
+		if err != nil {
+			return err
+		}
+		if err := t.Validate(prompt.SampleData(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Execute asks the LLM which tool answers the request and evaluates it. The
+// result maps each of the tool's documented return values to its value.
+func (a *Agent) Execute(ctx context.Context, userRequest string) (result evaluation.Result, err error) {
+	ctx, span := a.tracer().Start(ctx, "agent.execute", trace.WithAttributes(attribute.String("agent.request", userRequest)))
+	defer span.End()
+
+	defer a.trackRequest(userRequest)(&err)
+
+	functionCall, tool, err := a.chooseCall(ctx, userRequest)
+	if err != nil {
+		return evaluation.Result{}, a.fail(ctx, userRequest, err)
+	}
+	if functionCall.Declined() {
+		return evaluation.Result{}, a.fail(ctx, userRequest, fmt.Errorf("%w: %s", ErrNoTool, functionCall.Answer))
+	}
+
+	return a.evaluate(ctx, userRequest, functionCall, tool)
+}
+
+// trackRequest reports the start of a request and returns a function that
+// reports its end with the final error.
+func (a *Agent) trackRequest(userRequest string) func(*error) {
+	start := time.Now()
+	a.emit(Event{Type: EventRequestStarted, Request: userRequest})
+
+	return func(err *error) {
+		a.emit(Event{Type: EventRequestFinished, Request: userRequest, Err: *err, Duration: time.Since(start)})
+	}
+}
+
+// chooseCall asks the LLM for a function call and looks up the tool, asking
+// again up to MaxRetries times if the reply is unusable. A declined call is
+// returned without a tool.
+func (a *Agent) chooseCall(ctx context.Context, userRequest string) (*FunctionCall, evaluation.Tool, error) {
+	for attempt := 0; ; attempt++ {
+		functionCall, err := a.callLLM(ctx, userRequest)
+		if err == nil && functionCall.Declined() {
+			return functionCall, evaluation.Tool{}, nil
+		}
+
+		var tool evaluation.Tool
+		if err == nil {
+			tool, err = a.lookupTool(functionCall.Function)
+			if err != nil {
+				a.emit(Event{Type: EventToolRejected, Request: userRequest, Call: functionCall, Tool: functionCall.Function, Err: err})
+			}
+		}
+		if err == nil {
+			return functionCall, tool, nil
+		}
+
+		if attempt >= a.MaxRetries || !retryable(err) {
+			return nil, evaluation.Tool{}, err
+		}
+		a.emit(Event{Type: EventRetry, Request: userRequest, Err: err, Attempt: attempt + 1})
+	}
+}
+
+// retryable reports whether asking the LLM again might fix the error.
+func retryable(err error) bool {
+	return errors.Is(err, ErrUnknownFunction) || errors.Is(err, ErrInvalidResponse)
+}
+
+// evaluate runs the tool chosen by the LLM, reporting its start and outcome.
+func (a *Agent) evaluate(ctx context.Context, userRequest string, functionCall *FunctionCall, tool evaluation.Tool) (evaluation.Result, error) {
+	ctx, span := a.tracer().Start(ctx, "tool.evaluate", trace.WithAttributes(
+		attribute.String("tool.name", functionCall.Function),
+		attribute.Int("tool.argument_count", len(functionCall.Arguments)),
+	))
+	defer span.End()
+
+	// Conversion errors are reported by Evaluate below, without running the
+	// tool, so only calls with valid arguments are authorized and put up for
+	// approval.
+	converted, err := tool.ConvertArguments(functionCall.Arguments)
+	if err == nil {
+		err = a.authorize(ctx, tool, functionCall.Function, converted)
+		if err == nil {
+			err = a.approve(ctx, userRequest, tool, functionCall.Function, converted)
+		}
+		if err != nil {
+			recordError(span, err, ErrorClass(err))
+			a.emit(Event{Type: EventToolRejected, Request: userRequest, Call: functionCall, Tool: functionCall.Function, Err: err})
+			return evaluation.Result{}, err
+		}
+	}
+
+	a.emit(Event{Type: EventToolStarted, Request: userRequest, Tool: functionCall.Function, Args: functionCall.Arguments, Converted: converted})
+
+	start := time.Now()
+	var executor evaluation.Executor = evaluation.InProcess{}
+	if a.Executor != nil {
+		executor = a.Executor
+	}
+	result, err := a.FunctionStore.Executor(executor).Execute(ctx, functionCall.Function, tool, functionCall.Arguments)
+	a.remember(userRequest, functionCall, result, err)
+
+	a.emit(Event{
+		Type:      EventToolFinished,
+		Request:   userRequest,
+		Tool:      functionCall.Function,
+		Args:      functionCall.Arguments,
+		Converted: converted,
+		Result:    &result,
+		Err:       err,
+		Duration:  time.Since(start),
+	})
+
+	span.SetAttributes(attribute.Bool("tool.cache_hit", result.Cached))
+	if err != nil {
+		recordError(span, err, ToolErrorClass(err))
+	}
+
+	return result, err
+}
+
+// lookupTool retrieves the tool named by the LLM.
+func (a *Agent) lookupTool(name string) (evaluation.Tool, error) {
+	tool, err := a.FunctionStore.GetTool(name)
+	if err != nil {
+		return evaluation.Tool{}, fmt.Errorf("%w: '%s'", ErrUnknownFunction, name)
+	}
+	return tool, nil
+}
+
+// CallLLM asks the LLM which function answers the request, without running it.
+func (a *Agent) CallLLM(ctx context.Context, userRequest string) (*FunctionCall, error) {
+	functionCall, err := a.callLLM(ctx, userRequest)
+	if err != nil {
+		return nil, a.fail(ctx, userRequest, err)
+	}
+	return functionCall, nil
+}
+
+func (a *Agent) callLLM(ctx context.Context, userRequest string) (*FunctionCall, error) {
+	finalPrompt, err := a.renderPrompt(ctx, userRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	// The reply is decoded under the caller's span, after the generation ended.
+	span := trace.SpanFromContext(ctx)
+
+	// Generate tokens for the final prompt
+	ctx, generation := a.startGeneration(ctx, PhaseCall)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := a.generateCall(ctx, finalPrompt)
+	if err != nil {
+		generation.end(err)
+		return nil, fmt.Errorf("error generating tokens: %w", err)
+	}
+
+	// Collect the generated tokens, parsing the call as it streams in so that
+	// an unknown function name stops the generation early.
+	var (
+		reply   strings.Builder
+		parser  callParser
+		checked bool
+	)
+	for token := range stream.Tokens {
+		generation.token()
+		a.emit(Event{Type: EventToken, Request: userRequest, Phase: PhaseCall, Token: token})
+		reply.WriteString(token)
+
+		for _, partial := range parser.Feed(token) {
+			a.emit(Event{Type: EventPartialCall, Request: userRequest, Phase: PhaseCall, Partial: &partial})
+
+			if partial.Function != "" && !checked {
+				checked = true
+				if _, err := a.lookupTool(partial.Function); err != nil {
+					a.stopGeneration(cancel, stream)
+					generation.finished(stream)
+					generation.end(err)
+					a.emit(generation.doneEvent(userRequest, PhaseCall, reply.String()))
+					a.emit(Event{Type: EventToolRejected, Request: userRequest, Tool: partial.Function, Err: err})
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if err := generation.finished(stream); err != nil {
+		err = fmt.Errorf("%w: %w", ErrGeneration, err)
+		generation.end(err)
+		a.emit(generation.doneEvent(userRequest, PhaseCall, reply.String()))
 		return nil, err
 	}
+	generation.end(nil)
+	a.emit(generation.doneEvent(userRequest, PhaseCall, reply.String()))
 
-	tool, err := a.FunctionStore.GetTool(functionCall.Function)
+	functionCall, err := decodeFunctionCall(reply.String())
 	if err != nil {
-		return nil, fmt.Errorf("function '%s' not found in tool store", functionCall.Function)
+		if generation.reason == llm.FinishLength {
+			return nil, fmt.Errorf("%w (the reply was cut off at the token limit)", err)
+		}
+		return nil, err
 	}
 
-	return tool.Evaluate(functionCall.Arguments)
+	if len(functionCall.Repairs) > 0 {
+		repairs := make([]string, len(functionCall.Repairs))
+		for i, repair := range functionCall.Repairs {
+			repairs[i] = string(repair)
+		}
+		span.SetAttributes(attribute.StringSlice("llm.repairs", repairs))
+	}
+	a.emit(Event{Type: EventCallParsed, Request: userRequest, Phase: PhaseCall, Call: functionCall})
+	return functionCall, nil
 }
 
-func (a *Agent) CallLLM(userRequest string) (*FunctionCall, error) {
-	// Execute the template to construct the final prompt
-	tmpl, err := template.New("llmPrompt").Parse(a.Prompt)
+// renderPrompt executes the prompt template for a request.
+func (a *Agent) renderPrompt(ctx context.Context, userRequest string) (string, error) {
+	_, span := a.tracer().Start(ctx, "agent.render_prompt")
+	defer span.End()
+
+	tmpl, err := prompt.Parse(prompt.Call, a.Prompt)
 	if err != nil {
-		return nil, fmt.Errorf("error creating template: %w", err)
+		recordError(span, err, "template")
+		return "", err
 	}
 
-	// Data for the template
-	data := struct {
-		Tools       string
-		UserRequest string
-	}{
-		Tools:       combineToolsDoc(a.FunctionStore),
-		UserRequest: userRequest,
+	data, err := a.promptData(userRequest)
+	if err != nil {
+		recordError(span, err, "template")
+		return "", err
 	}
 
-	// Write the template output to a buffer (or directly to a string)
 	var finalPrompt strings.Builder
 	if err := tmpl.Execute(&finalPrompt, data); err != nil {
-		return nil, fmt.Errorf("error executing template: %w", err)
+		recordError(span, err, "template")
+		return "", fmt.Errorf("error executing template: %w", err)
 	}
 
-	// Print the final prompt for debugging
-	// fmt.Println("Final Prompt:\n", finalPrompt.String())
+	span.SetAttributes(attribute.Int("prompt.length", finalPrompt.Len()))
+	a.emit(Event{Type: EventPromptBuilt, Request: userRequest, Phase: PhaseCall, Prompt: finalPrompt.String()})
 
-	// Generate tokens for the final prompt
-	tokenCh, err := a.Engine.GenerateTokens(context.Background(), finalPrompt.String())
+	return finalPrompt.String(), nil
+}
+
+// stopGeneration aborts a generation in progress and drains its stream.
+func (a *Agent) stopGeneration(cancel context.CancelFunc, stream *llm.Stream) {
+	if stopper, ok := a.Engine.(Stopper); ok && stream.ID != 0 {
+		// The generation may already have finished, in which case there is nothing to stop.
+		_ = stopper.StopGeneration(context.Background(), stream.ID)
+	}
+	cancel()
+
+	// The generation ends as canceled; that is not a failure.
+	_ = stream.Drain()
+}
+
+// generateCall generates the function call, constrained by the tool grammar
+// when the engine supports it.
+func (a *Agent) generateCall(ctx context.Context, prompt string) (*llm.Stream, error) {
+	constrained, ok := a.Engine.(ConstrainedEngine)
+	if !ok || a.Unconstrained {
+		return a.Engine.GenerateTokens(ctx, prompt)
+	}
+
+	g, err := grammar.FromToolStore(a.FunctionStore, grammar.AllowAnswer())
 	if err != nil {
-		return nil, fmt.Errorf("error generating tokens: %w", err)
+		return nil, fmt.Errorf("error building grammar: %w", err)
 	}
+	return constrained.GenerateConstrained(ctx, prompt, g)
+}
 
-	// Collect the generated tokens
-	var reply string
-	for token := range tokenCh {
-		fmt.Print(token)
-		reply += token
+// decodeFunctionCall extracts the JSON function call from an LLM reply,
+// tolerating markdown fences, surrounding prose and common syntax defects.
+func decodeFunctionCall(reply string) (*FunctionCall, error) {
+	extracted, err := jsonextract.Extract(reply)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
 	}
-	fmt.Println()
-	fmt.Println("------------------------------")
 
 	var functionCall FunctionCall
-	// Decode the LLM's response into the Go struct
-	if err := json.Unmarshal([]byte(reply), &functionCall); err != nil {
-		return nil, fmt.Errorf("error decoding LLM response: %w", err)
+	// Decode the LLM's response into the Go struct. Numbers are kept as
+	// json.Number so that integer and floating-point arguments can be told
+	// apart when choosing the instantiation of a generic tool.
+	decoder := json.NewDecoder(strings.NewReader(extracted.JSON))
+	decoder.UseNumber()
+	if err := decoder.Decode(&functionCall); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	if functionCall.Declined() && functionCall.Answer == "" {
+		return nil, fmt.Errorf("%w: the reply names no function", ErrInvalidResponse)
 	}
+	functionCall.Repairs = extracted.Repairs
 
 	return &functionCall, nil
 }
 
-// CombineToolsDoc combines the documentation of all tools in the ToolStore.
-func combineToolsDoc(ts *toolstore.ToolStore) string {
+// collectTokens reports the tokens as they arrive and returns the full reply,
+// or the error that ended the generation.
+func (a *Agent) collectTokens(userRequest string, phase Phase, generation *generationSpan, stream *llm.Stream) (string, error) {
+	var reply strings.Builder
+	for token := range stream.Tokens {
+		generation.token()
+		a.emit(Event{Type: EventToken, Request: userRequest, Phase: phase, Token: token})
+		reply.WriteString(token)
+	}
 
+	err := generation.finished(stream)
+	if err != nil {
+		err = fmt.Errorf("%w: %w", ErrGeneration, err)
+	}
+	generation.end(err)
+	a.emit(generation.doneEvent(userRequest, phase, reply.String()))
+	return reply.String(), err
+}
+
+// promptData gathers the data for the call template.
+func (a *Agent) promptData(userRequest string) (prompt.Data, error) {
+	tools, err := toolList(a.FunctionStore, a.ToolPrompts)
+	if err != nil {
+		return prompt.Data{}, err
+	}
+
+	g, err := grammar.FromToolStore(a.FunctionStore, grammar.AllowAnswer())
+	if err != nil {
+		return prompt.Data{}, fmt.Errorf("error building grammar: %w", err)
+	}
+	schema, err := g.SchemaJSON()
+	if err != nil {
+		return prompt.Data{}, fmt.Errorf("error encoding schema: %w", err)
+	}
+
+	examples := exampleList(a.FunctionStore)
+
+	a.mu.RLock()
+	history := append([]prompt.Turn(nil), a.history...)
+	a.mu.RUnlock()
+
+	domain := a.Domain
+	if domain == "" {
+		domain = domainSummary(a.FunctionStore)
+	}
+
+	return prompt.Data{
+		Persona:     a.Persona,
+		Domain:      domain,
+		UserRequest: userRequest,
+		Tools:       combineToolsDoc(tools),
+		ToolList:    tools,
+		Schema:      string(schema),
+		Examples:    combineExamples(examples),
+		ExampleList: examples,
+		History:     history,
+		Date:        time.Now().Format(time.DateOnly),
+	}, nil
+}
+
+// domainSummary lists the synopsis of each package the tools come from, one per line.
+func domainSummary(ts *toolstore.ToolStore) string {
+	var synopses []string
+	for _, pkg := range ts.Packages() {
+		if pkg.Synopsis != "" {
+			synopses = append(synopses, pkg.Synopsis)
+		}
+	}
+	return strings.Join(synopses, "\n")
+}
+
+// remember adds a handled request to the history passed to the prompt,
+// keeping the last HistorySize requests.
+func (a *Agent) remember(userRequest string, functionCall *FunctionCall, result evaluation.Result, err error) {
+	if a.HistorySize <= 0 {
+		return
+	}
+
+	turn := prompt.Turn{Request: userRequest}
+	if call, marshalErr := json.Marshal(functionCall); marshalErr == nil {
+		turn.Call = string(call)
+	}
+	if err != nil {
+		turn.Error = err.Error()
+	} else {
+		turn.Result = result.String()
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.history = append(a.history, turn)
+	if len(a.history) > a.HistorySize {
+		a.history = a.history[len(a.history)-a.HistorySize:]
+	}
+}
+
+// toolList describes each tool for the prompt, rendering its override in
+// place of the generated documentation when there is one.
+func toolList(ts *toolstore.ToolStore, overrides map[string]string) ([]prompt.Tool, error) {
+	var tools []prompt.Tool
+
+	for _, name := range sortedToolNames(ts) {
+		tool := ts.Tools()[name]
+		entry := prompt.Tool{Name: name, Metadata: tool.Metadata, Doc: generatePrompt(tool.Metadata)}
+		if instantiations, ok := tool.Function.(evaluation.Instantiations); ok {
+			entry.TypeArguments = instantiations.TypeArguments()
+			entry.Doc += fmt.Sprintf("Available for type arguments: %s\n", strings.Join(entry.TypeArguments, ", "))
+		}
+
+		if text, ok := overrides[name]; ok {
+			tmpl, err := prompt.Parse(prompt.ToolPrefix+name, text)
+			if err != nil {
+				return nil, err
+			}
+			var doc strings.Builder
+			if err := tmpl.Execute(&doc, entry); err != nil {
+				return nil, fmt.Errorf("error executing template for %s: %w", name, err)
+			}
+			entry.Doc = doc.String()
+		}
+
+		tools = append(tools, entry)
+	}
+
+	return tools, nil
+}
+
+// combineToolsDoc combines the documentation of all tools.
+func combineToolsDoc(tools []prompt.Tool) string {
 	var combinedPrompt strings.Builder
 	combinedPrompt.WriteString("=== Combined Function Prompts ===\n\n")
 
-	for functionName, entry := range ts.Tools() {
-		combinedPrompt.WriteString(fmt.Sprintf("--- Function: %s ---\n", functionName))
-		combinedPrompt.WriteString(generatePrompt(entry.Metadata))
+	for _, tool := range tools {
+		combinedPrompt.WriteString(fmt.Sprintf("--- Function: %s ---\n", tool.Name))
+		combinedPrompt.WriteString(tool.Doc)
 		combinedPrompt.WriteString("\n\n")
 	}
 
 	return combinedPrompt.String()
 }
 
+// exampleList turns the documented examples of all tools into few-shot
+// demonstrations, pairing each user phrase with the JSON call it should produce.
+func exampleList(ts *toolstore.ToolStore) []prompt.Example {
+	var examples []prompt.Example
+
+	for _, name := range sortedToolNames(ts) {
+		for _, example := range ts.Tools()[name].Metadata.Examples {
+			if example.Function == "" {
+				continue
+			}
+
+			call, err := json.Marshal(FunctionCall{Function: example.Function, Arguments: example.Args})
+			if err != nil {
+				continue
+			}
+
+			examples = append(examples, prompt.Example{Request: example.Phrase(), Call: string(call)})
+		}
+	}
+
+	return examples
+}
+
+// combineExamples renders the demonstrations as "User Request: ... Response: ..." pairs.
+func combineExamples(examples []prompt.Example) string {
+	var combined strings.Builder
+	for _, example := range examples {
+		combined.WriteString(fmt.Sprintf("User Request: %s\nResponse: %s\n\n", example.Request, example.Call))
+	}
+	return combined.String()
+}
+
+// sortedToolNames returns the tool names in a stable order so that prompts are reproducible.
+func sortedToolNames(ts *toolstore.ToolStore) []string {
+	names := ts.ListToolNames()
+	sort.Strings(names)
+	return names
+}
+
 // generatePrompt creates a human-readable prompt for a function based on its metadata.
 func generatePrompt(meta metadata.FunctionMetaData) string {
 	var prompt strings.Builder
 
 	prompt.WriteString(fmt.Sprintf("Function: %s\nDescription: %s\n", meta.FunctionName, meta.Description))
 
+	if meta.Deprecated != "" {
+		prompt.WriteString(fmt.Sprintf("Deprecated: %s\n", meta.Deprecated))
+	}
+
+	if meta.Since != "" {
+		prompt.WriteString(fmt.Sprintf("Since: %s\n", meta.Since))
+	}
+
+	if meta.Dangerous != "" {
+		prompt.WriteString(fmt.Sprintf("Dangerous: %s\n", meta.Dangerous))
+	}
+
+	if len(meta.SideEffects) > 0 {
+		prompt.WriteString("Side Effects:\n")
+		for _, effect := range meta.SideEffects {
+			prompt.WriteString(fmt.Sprintf("  - %s\n", effect))
+		}
+	}
+
+	if len(meta.TypeParams) > 0 {
+		prompt.WriteString("Type Parameters:\n")
+		for _, typeParam := range meta.TypeParams {
+			prompt.WriteString(fmt.Sprintf("  - %s: %s\n", typeParam.Name, typeParam.Constraint))
+		}
+	}
+
 	if len(meta.Params) > 0 {
 		prompt.WriteString("Parameters:\n")
 		for _, param := range meta.Params {
-			prompt.WriteString(fmt.Sprintf("  - %s: %s\n", param.Name, param.Desc))
+			prompt.WriteString(fmt.Sprintf("  - %s: %s%s\n", param.Name, param.Desc, paramDetails(param)))
 		}
 	}
 
 	if len(meta.Return) > 0 {
 		prompt.WriteString("Returns:\n")
 		for _, ret := range meta.Return {
+			if ret.Name != "" {
+				prompt.WriteString(fmt.Sprintf("  - %s (%s): %s\n", ret.Name, ret.Type, ret.Description))
+				continue
+			}
 			prompt.WriteString(fmt.Sprintf("  - %s: %s\n", ret.Type, ret.Description))
 		}
 	}
@@ -152,12 +727,41 @@ func generatePrompt(meta metadata.FunctionMetaData) string {
 		}
 	}
 
+	if len(meta.Errors) > 0 {
+		prompt.WriteString("Errors:\n")
+		for _, e := range meta.Errors {
+			prompt.WriteString(fmt.Sprintf("  - %s\n", e))
+		}
+	}
+
 	if len(meta.Examples) > 0 {
 		prompt.WriteString("Examples:\n")
 		for _, example := range meta.Examples {
-			prompt.WriteString(fmt.Sprintf("  - %s\n", example))
+			prompt.WriteString(fmt.Sprintf("  - %s\n", example.Raw))
 		}
 	}
 
+	if len(meta.See) > 0 {
+		prompt.WriteString(fmt.Sprintf("See also: %s\n", strings.Join(meta.See, ", ")))
+	}
+
 	return prompt.String()
 }
+
+// paramDetails renders the optional unit, default and allowed values of a parameter.
+func paramDetails(param metadata.Param) string {
+	var details []string
+	if param.Unit != "" {
+		details = append(details, "unit: "+param.Unit)
+	}
+	if param.Default != "" {
+		details = append(details, "default: "+param.Default)
+	}
+	if len(param.Enum) > 0 {
+		details = append(details, "one of: "+strings.Join(param.Enum, ", "))
+	}
+	if len(details) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(details, "; ") + ")"
+}
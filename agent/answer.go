@@ -0,0 +1,111 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-agent/prompt"
+	"go-agent/tools/evaluation"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Response is the outcome of Respond: the structured call and result, plus
+// the natural-language answer when answer synthesis is enabled.
+type Response struct {
+	Request string            `json:"request"`
+	Call    *FunctionCall     `json:"call"`
+	Result  evaluation.Result `json:"result"`
+	Err     error             `json:"-"`                // Error returned by the tool itself
+	Answer  string            `json:"answer,omitempty"` // Natural-language answer, if synthesized
+}
+
+// Respond handles a request end to end. Unlike Execute, an error returned by
+// the tool (e.g. "division by zero is not allowed") does not fail the call: it
+// is recorded in Response.Err so that it can be explained in the answer. Only
+// failures to obtain or look up a function call are returned as errors. When
+// no tool applies, the LLM's own reply is returned as the answer without a
+// result.
+func (a *Agent) Respond(ctx context.Context, userRequest string) (_ *Response, err error) {
+	ctx, span := a.tracer().Start(ctx, "agent.respond", trace.WithAttributes(attribute.String("agent.request", userRequest)))
+	defer span.End()
+
+	defer a.trackRequest(userRequest)(&err)
+
+	functionCall, tool, err := a.chooseCall(ctx, userRequest)
+	if err != nil {
+		return nil, a.fail(ctx, userRequest, err)
+	}
+
+	response := &Response{Request: userRequest, Call: functionCall}
+	if functionCall.Declined() {
+		// The LLM has already answered the user.
+		response.Answer = functionCall.Answer
+		return response, nil
+	}
+
+	response.Result, response.Err = a.evaluate(ctx, userRequest, functionCall, tool)
+
+	if !a.SynthesizeAnswer {
+		return response, nil
+	}
+
+	response.Answer, err = a.synthesizeAnswer(ctx, response)
+	if err != nil {
+		return response, a.fail(ctx, userRequest, err)
+	}
+
+	return response, nil
+}
+
+// synthesizeAnswer runs the answer prompt through the answer engine, streaming
+// the tokens as they are generated.
+func (a *Agent) synthesizeAnswer(ctx context.Context, response *Response) (string, error) {
+	tmpl, err := prompt.Parse(prompt.Answer, a.AnswerPrompt)
+	if err != nil {
+		return "", err
+	}
+
+	call, err := json.Marshal(response.Call)
+	if err != nil {
+		return "", fmt.Errorf("error encoding function call: %w", err)
+	}
+
+	data := prompt.AnswerData{
+		UserRequest: response.Request,
+		Call:        string(call),
+		Result:      response.Result.String(),
+		Date:        time.Now().Format(time.DateOnly),
+	}
+	if response.Err != nil {
+		data.Error = response.Err.Error()
+	}
+
+	var answerPrompt strings.Builder
+	if err := tmpl.Execute(&answerPrompt, data); err != nil {
+		return "", fmt.Errorf("error executing answer template: %w", err)
+	}
+
+	a.emit(Event{Type: EventPromptBuilt, Request: response.Request, Phase: PhaseAnswer, Prompt: answerPrompt.String()})
+
+	engine := a.AnswerEngine
+	if engine == nil {
+		engine = a.Engine
+	}
+
+	ctx, generation := a.startGeneration(ctx, PhaseAnswer)
+	stream, err := engine.GenerateTokens(ctx, answerPrompt.String())
+	if err != nil {
+		generation.end(err)
+		return "", fmt.Errorf("error generating answer: %w", err)
+	}
+
+	answer, err := a.collectTokens(response.Request, PhaseAnswer, generation, stream)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(answer), nil
+}
@@ -0,0 +1,92 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-agent/tools/evaluation"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrNotApproved is returned when a tool that requires approval is denied.
+var ErrNotApproved = errors.New("tool call was not approved")
+
+// ApprovalRequest describes a pending call of a tool documented with
+// @sideeffect or @dangerous.
+type ApprovalRequest struct {
+	Request     string                  `json:"request"`
+	Tool        string                  `json:"tool"`
+	Args        []evaluation.NamedValue `json:"args"` // Arguments converted to the tool's parameter types
+	SideEffects []string                `json:"side_effects,omitempty"`
+	Dangerous   string                  `json:"dangerous,omitempty"`
+}
+
+// Decision is an approver's verdict on an ApprovalRequest.
+type Decision struct {
+	Approved bool      `json:"approved"`
+	Approver string    `json:"approver"`         // Who or what decided, e.g. "cli"
+	Reason   string    `json:"reason,omitempty"` // Why, if given
+	Time     time.Time `json:"time"`
+}
+
+// Approver decides whether a tool call that requires approval may run.
+// Approve blocks until a decision is made or ctx is done.
+type Approver interface {
+	Approve(ctx context.Context, req ApprovalRequest) (Decision, error)
+}
+
+// ApproverFunc adapts a function to the Approver interface.
+type ApproverFunc func(ctx context.Context, req ApprovalRequest) (Decision, error)
+
+func (f ApproverFunc) Approve(ctx context.Context, req ApprovalRequest) (Decision, error) {
+	return f(ctx, req)
+}
+
+// approve asks the agent's Approver whether the tool may run. Tools that do
+// not require approval always may; without an Approver, those that do are
+// denied.
+func (a *Agent) approve(ctx context.Context, userRequest string, tool evaluation.Tool, name string, args []evaluation.NamedValue) error {
+	if !tool.Metadata.RequiresApproval() {
+		return nil
+	}
+
+	ctx, span := a.tracer().Start(ctx, "tool.approve", trace.WithAttributes(attribute.String("tool.name", name)))
+	defer span.End()
+
+	req := ApprovalRequest{
+		Request:     userRequest,
+		Tool:        name,
+		Args:        args,
+		SideEffects: tool.Metadata.SideEffects,
+		Dangerous:   tool.Metadata.Dangerous,
+	}
+	a.emit(Event{Type: EventApprovalRequested, Request: userRequest, Tool: name, Converted: args})
+
+	decision := Decision{Approver: "none", Reason: "no approver configured"}
+	if a.Approver != nil {
+		var err error
+		decision, err = a.Approver.Approve(ctx, req)
+		if err != nil {
+			decision = Decision{Approver: decision.Approver, Reason: err.Error()}
+		}
+	}
+	if decision.Time.IsZero() {
+		decision.Time = time.Now()
+	}
+
+	span.SetAttributes(
+		attribute.Bool("approval.approved", decision.Approved),
+		attribute.String("approval.approver", decision.Approver),
+	)
+	a.emit(Event{Type: EventApprovalDecided, Request: userRequest, Tool: name, Converted: args, Approval: &decision})
+
+	if !decision.Approved {
+		err := fmt.Errorf("%w: %s", ErrNotApproved, decision.Reason)
+		recordError(span, err, ErrorClass(err))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"go-agent/metadata"
+	"go-agent/tools/evaluation"
+	"go-agent/tools/toolstore"
+)
+
+// ErrDenied is wrapped by the errors an Authorizer returns to deny a call.
+var ErrDenied = errors.New("tool call denied by policy")
+
+// Caller identifies who made a request, for access control.
+type Caller struct {
+	ID     string   `json:"id"`
+	Groups []string `json:"groups,omitempty"` // E.g. tenants or roles the caller belongs to
+}
+
+type callerKey struct{}
+
+// WithCaller returns a context carrying the caller of the request.
+func WithCaller(ctx context.Context, caller Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFrom returns the caller carried by ctx, or the zero Caller.
+func CallerFrom(ctx context.Context) Caller {
+	caller, _ := ctx.Value(callerKey{}).(Caller)
+	return caller
+}
+
+// AuthorizationRequest describes a tool call to be authorized.
+type AuthorizationRequest struct {
+	Caller   Caller
+	Tool     string // Name of the tool without type arguments, e.g. "Max" for a call to "Max[int]"
+	Metadata metadata.FunctionMetaData
+	Args     []evaluation.NamedValue // Arguments converted to the tool's parameter types
+}
+
+// Authorizer decides whether a caller may run a tool call. It returns nil to
+// allow the call, or an error wrapping ErrDenied.
+type Authorizer interface {
+	Authorize(ctx context.Context, req AuthorizationRequest) error
+}
+
+// authorize checks the call against the agent's Authorizer, if any.
+func (a *Agent) authorize(ctx context.Context, tool evaluation.Tool, name string, args []evaluation.NamedValue) error {
+	if a.Authorizer == nil {
+		return nil
+	}
+	return a.Authorizer.Authorize(ctx, AuthorizationRequest{
+		Caller:   CallerFrom(ctx),
+		Tool:     toolstore.BaseName(name),
+		Metadata: tool.Metadata,
+		Args:     args,
+	})
+}
@@ -0,0 +1,32 @@
+package agent
+
+import (
+	"fmt"
+	"io"
+)
+
+// ConsolePrinter is an Observer that prints the generated tokens as they
+// stream in, followed by a separator once each generation is done.
+type ConsolePrinter struct {
+	w          io.Writer
+	ShowPrompt bool // Also print each rendered prompt, for debugging
+}
+
+// NewConsolePrinter creates a ConsolePrinter writing to w.
+func NewConsolePrinter(w io.Writer) *ConsolePrinter {
+	return &ConsolePrinter{w: w}
+}
+
+func (p *ConsolePrinter) OnEvent(e Event) {
+	switch e.Type {
+	case EventPromptBuilt:
+		if p.ShowPrompt {
+			fmt.Fprintln(p.w, "Final Prompt:\n", e.Prompt)
+		}
+	case EventToken:
+		fmt.Fprint(p.w, e.Token)
+	case EventGenerationDone:
+		fmt.Fprintln(p.w)
+		fmt.Fprintln(p.w, "------------------------------")
+	}
+}
@@ -0,0 +1,127 @@
+package agent
+
+import (
+	"context"
+	"go-agent/llm"
+	"go-agent/tools/evaluation"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// EventType identifies what happened in an Event.
+type EventType string
+
+const (
+	EventRequestStarted    EventType = "request_started"    // Execute or Respond began handling Request
+	EventRequestFinished   EventType = "request_finished"   // The request completed; Err is set if it failed
+	EventPromptBuilt       EventType = "prompt_built"       // The prompt was rendered; see Prompt
+	EventToken             EventType = "token"              // The engine produced a token; see Token
+	EventGenerationDone    EventType = "generation_done"    // The token stream ended; see Text
+	EventPartialCall       EventType = "partial_call"       // The call being generated advanced; see Partial
+	EventCallParsed        EventType = "call_parsed"        // The reply was decoded; see Call and its Repairs
+	EventApprovalRequested EventType = "approval_requested" // A tool needs approval before it runs; see Tool and Converted
+	EventApprovalDecided   EventType = "approval_decided"   // The approver decided; see Approval
+	EventToolStarted       EventType = "tool_started"       // A tool is about to run; see Tool and Args
+	EventToolFinished      EventType = "tool_finished"      // A tool returned; see Result and Err
+	EventToolRejected      EventType = "tool_rejected"      // The called tool cannot be run; see Tool and Err
+	EventRetry             EventType = "retry"              // The call is requested again after Err; see Attempt
+	EventError             EventType = "error"              // The request failed; see Err
+)
+
+// Phase tells which LLM pass an event belongs to.
+type Phase string
+
+const (
+	PhaseCall   Phase = "call"   // Choosing the function to call
+	PhaseAnswer Phase = "answer" // Writing the natural-language answer
+)
+
+// Event describes a step of the agent handling a request. Only the fields
+// relevant to the event type are set.
+type Event struct {
+	Type      EventType
+	Time      time.Time
+	Request   string // The user request being handled
+	Phase     Phase
+	Prompt    string
+	Token     string
+	Text      string // Full reply of a finished generation
+	Partial   *PartialCall
+	Call      *FunctionCall
+	Tool      string
+	Args      []any
+	Converted []evaluation.NamedValue // Args converted to the tool's parameter types
+	Result    *evaluation.Result
+	Approval  *Decision
+	Err       error
+	Attempt   int              // Retry number, starting at 1
+	Tokens    int              // Number of tokens in a finished generation
+	TTFT      time.Duration    // Time to first token of a finished generation
+	Finish    llm.FinishReason // Why a finished generation ended
+	Usage     llm.Usage        // Token counts of a finished generation, as reported by the engine
+	Duration  time.Duration    // Time taken by the request, generation or tool
+}
+
+// Observer receives the events of an agent. OnEvent is called synchronously
+// from the goroutine handling the request, so it should return quickly.
+type Observer interface {
+	OnEvent(Event)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(Event)
+
+func (f ObserverFunc) OnEvent(e Event) {
+	f(e)
+}
+
+type subscription struct {
+	id       int
+	observer Observer
+}
+
+// Subscribe registers an observer for the agent's events and returns a
+// function that removes it again.
+func (a *Agent) Subscribe(observer Observer) (unsubscribe func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.nextID++
+	id := a.nextID
+	a.observers = append(a.observers, subscription{id: id, observer: observer})
+
+	return func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		for i, s := range a.observers {
+			if s.id == id {
+				a.observers = append(a.observers[:i:i], a.observers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// emit delivers an event to all observers.
+func (a *Agent) emit(event Event) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+
+	a.mu.RLock()
+	observers := a.observers
+	a.mu.RUnlock()
+
+	for _, s := range observers {
+		s.observer.OnEvent(event)
+	}
+}
+
+// fail reports an error event for the request, records the error on the
+// current span, and returns the error.
+func (a *Agent) fail(ctx context.Context, userRequest string, err error) error {
+	recordError(trace.SpanFromContext(ctx), err, ErrorClass(err))
+	a.emit(Event{Type: EventError, Request: userRequest, Err: err})
+	return err
+}
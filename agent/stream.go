@@ -0,0 +1,152 @@
+package agent
+
+import "strings"
+
+// PartialCall is the state of a function call while the LLM is still
+// generating it. A new PartialCall is reported each time the state advances.
+type PartialCall struct {
+	Function  string `json:"function,omitempty"` // Function name, once fully emitted
+	Arguments int    `json:"arguments"`          // Number of arguments completely emitted so far
+	Complete  bool   `json:"complete"`           // The JSON object has been closed
+}
+
+// callParser incrementally parses a streamed function call of the form
+// {"function": "<name>", "arguments": [...]}. It only tracks as much of
+// the JSON structure as needed to detect the function name and count the
+// arguments; the complete reply is still decoded by decodeFunctionCall.
+type callParser struct {
+	state PartialCall
+
+	started   bool
+	depth     int
+	inString  bool
+	quote     byte
+	escaped   bool
+	buf       strings.Builder // Current string or bare word
+	key       string          // Last key seen in the top-level object
+	expectKey bool
+	inArgs    bool // Inside the top-level "arguments" array
+	argValue  bool // The current argument has at least one character
+}
+
+// Feed consumes a token and returns the states reached while consuming it.
+func (p *callParser) Feed(token string) []PartialCall {
+	var updates []PartialCall
+	for i := 0; i < len(token) && !p.state.Complete; i++ {
+		if p.consume(token[i]) {
+			updates = append(updates, p.state)
+		}
+	}
+	return updates
+}
+
+// consume processes one byte and reports whether the state advanced.
+func (p *callParser) consume(c byte) bool {
+	if !p.started {
+		if c == '{' {
+			p.started, p.depth, p.expectKey = true, 1, true
+		}
+		return false
+	}
+
+	if p.inString {
+		switch {
+		case p.escaped:
+			p.escaped = false
+			p.buf.WriteByte(c)
+		case c == '\\':
+			p.escaped = true
+		case c == p.quote:
+			p.inString = false
+			return p.finishValue(true)
+		default:
+			p.buf.WriteByte(c)
+		}
+		return false
+	}
+
+	switch c {
+	case '"', '\'':
+		p.inString, p.quote = true, c
+		p.buf.Reset()
+		p.markArgument()
+	case ':':
+		if p.depth == 1 {
+			if p.buf.Len() > 0 {
+				p.key = p.buf.String()
+			}
+			p.buf.Reset()
+			p.expectKey = false
+		}
+	case ',':
+		advanced := p.finishValue(false)
+		if p.depth == 1 {
+			p.expectKey = true
+		}
+		if p.depth == 2 && p.inArgs {
+			return p.finishArgument() || advanced
+		}
+		return advanced
+	case '[', '{':
+		p.markArgument()
+		p.depth++
+		if p.depth == 2 && c == '[' && p.key == "arguments" {
+			p.inArgs = true
+		}
+	case ']', '}':
+		advanced := p.finishValue(false)
+		if p.depth == 2 && p.inArgs {
+			advanced = p.finishArgument() || advanced
+			p.inArgs = false
+		}
+		p.depth--
+		if p.depth == 0 {
+			p.state.Complete = true
+			return true
+		}
+		return advanced
+	case ' ', '\t', '\n', '\r':
+	default:
+		p.buf.WriteByte(c)
+		p.markArgument()
+	}
+	return false
+}
+
+// finishValue handles the end of a string or bare word. Strings at the top
+// level are either keys or, after the "function" key, the function name.
+func (p *callParser) finishValue(quoted bool) bool {
+	value := p.buf.String()
+	if !quoted && value == "" {
+		return false
+	}
+	p.buf.Reset()
+
+	if p.depth != 1 {
+		return false
+	}
+	if p.expectKey {
+		p.key = value
+		return false
+	}
+	if p.key == "function" && quoted && p.state.Function == "" {
+		p.state.Function = value
+		return true
+	}
+	return false
+}
+
+func (p *callParser) markArgument() {
+	if p.inArgs && p.depth == 2 {
+		p.argValue = true
+	}
+}
+
+func (p *callParser) finishArgument() bool {
+	if !p.argValue {
+		return false
+	}
+	p.argValue = false
+	p.state.Arguments++
+	return true
+}
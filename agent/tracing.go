@@ -0,0 +1,157 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"go-agent/llm"
+	"go-agent/llm/jsonextract"
+	"go-agent/tools/evaluation"
+	"go-agent/tools/toolstore"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "go-agent/agent"
+
+func (a *Agent) tracer() trace.Tracer {
+	provider := a.TracerProvider
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName)
+}
+
+// generationSpan traces a call to the engine, counting tokens and measuring
+// the latency to the first token.
+type generationSpan struct {
+	span       trace.Span
+	start      time.Time
+	tokens     int
+	firstToken time.Duration
+	reason     llm.FinishReason
+	usage      llm.Usage
+}
+
+func (a *Agent) startGeneration(ctx context.Context, phase Phase) (context.Context, *generationSpan) {
+	ctx, span := a.tracer().Start(ctx, "llm.generate_tokens", trace.WithAttributes(
+		attribute.String("llm.phase", string(phase)),
+	))
+	return ctx, &generationSpan{span: span, start: time.Now()}
+}
+
+func (g *generationSpan) token() {
+	if g.tokens == 0 {
+		g.firstToken = time.Since(g.start)
+	}
+	g.tokens++
+}
+
+// finished records how the stream ended and returns its error. The tokens
+// must have been drained.
+func (g *generationSpan) finished(stream *llm.Stream) error {
+	g.reason = stream.FinishReason()
+	g.usage = stream.Usage()
+	return stream.Err()
+}
+
+// doneEvent describes the finished generation.
+func (g *generationSpan) doneEvent(userRequest string, phase Phase, reply string) Event {
+	return Event{
+		Type:     EventGenerationDone,
+		Request:  userRequest,
+		Phase:    phase,
+		Text:     reply,
+		Tokens:   g.tokens,
+		TTFT:     g.firstToken,
+		Duration: time.Since(g.start),
+		Finish:   g.reason,
+		Usage:    g.usage,
+	}
+}
+
+func (g *generationSpan) end(err error) {
+	g.span.SetAttributes(attribute.Int("llm.tokens", g.tokens))
+	if g.reason != "" {
+		g.span.SetAttributes(
+			attribute.String("llm.finish_reason", string(g.reason)),
+			attribute.Int("llm.usage.prompt_tokens", g.usage.PromptTokens),
+			attribute.Int("llm.usage.completion_tokens", g.usage.CompletionTokens),
+		)
+	}
+	if g.tokens > 0 {
+		g.span.SetAttributes(attribute.Int64("llm.time_to_first_token_ms", g.firstToken.Milliseconds()))
+	}
+	if err != nil {
+		recordError(g.span, err, ErrorClass(err))
+	}
+	g.span.End()
+}
+
+// recordError marks a span as failed and tags it with the error class.
+func recordError(span trace.Span, err error, class string) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.SetAttributes(attribute.String("error.class", class))
+}
+
+// ErrorClass maps an error to a short, stable name suitable for span
+// attributes and metric labels, based on the sentinel errors it wraps. Errors
+// of other packages, such as those of a sandbox executor, can name their own
+// class with an ErrorClass() string method.
+func ErrorClass(err error) string {
+	var classed interface{ ErrorClass() string }
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrUnknownFunction), errors.Is(err, toolstore.ErrToolNotFound):
+		return "tool_not_found"
+	case errors.Is(err, evaluation.ErrNotAFunction):
+		return "not_a_function"
+	case errors.Is(err, evaluation.ErrArgumentMismatch):
+		return "argument_mismatch"
+	case errors.Is(err, evaluation.ErrArgumentType):
+		return "argument_type"
+	case errors.Is(err, evaluation.ErrFunctionPanic):
+		return "function_panic"
+	case errors.Is(err, evaluation.ErrNoInstantiation):
+		return "no_instantiation"
+	case errors.Is(err, ErrInvalidResponse), errors.Is(err, jsonextract.ErrNoJSON), errors.Is(err, jsonextract.ErrInvalidJSON):
+		return "invalid_response"
+	case errors.Is(err, ErrDenied):
+		return "policy_denied"
+	case errors.Is(err, ErrNotApproved):
+		return "not_approved"
+	case errors.Is(err, ErrNoTool):
+		return "no_tool"
+	case errors.Is(err, toolstore.ErrConcurrencyLimit):
+		return "concurrency_limit"
+	case errors.Is(err, toolstore.ErrRateLimited):
+		return "rate_limited"
+	case errors.Is(err, toolstore.ErrCircuitOpen):
+		return "circuit_open"
+	case errors.As(err, &classed):
+		return classed.ErrorClass()
+	case errors.Is(err, toolstore.ErrToolTimeout), errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, ErrGeneration), errors.Is(err, llm.ErrAllEnginesFailed):
+		return "llm_error"
+	default:
+		return "error"
+	}
+}
+
+// ToolErrorClass is like ErrorClass, but classes errors returned by the tool
+// function itself, such as "division by zero is not allowed", as user_error.
+func ToolErrorClass(err error) string {
+	class := ErrorClass(err)
+	if class == "error" {
+		return "user_error"
+	}
+	return class
+}
@@ -0,0 +1,148 @@
+// Package approval provides agent.Approver implementations for tools
+// documented with @sideeffect or @dangerous.
+package approval
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-agent/agent"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AutoDeny returns an approver that denies every call with the given reason.
+func AutoDeny(reason string) agent.Approver {
+	return agent.ApproverFunc(func(ctx context.Context, req agent.ApprovalRequest) (agent.Decision, error) {
+		return agent.Decision{Approved: false, Approver: "auto-deny", Reason: reason, Time: time.Now()}, nil
+	})
+}
+
+// CLI asks for approval on a terminal, showing the call and its arguments
+// and reading a yes/no answer. Concurrent requests are asked one at a time.
+type CLI struct {
+	mu    sync.Mutex
+	in    io.Reader
+	out   io.Writer
+	once  sync.Once
+	lines chan string
+}
+
+// NewCLI creates a CLI approver reading answers from in and writing prompts to out.
+func NewCLI(in io.Reader, out io.Writer) *CLI {
+	return &CLI{in: in, out: out, lines: make(chan string, 1)}
+}
+
+// readLines reads answers in the background, so that a cancelled request
+// does not wait for input.
+func (c *CLI) readLines() {
+	scanner := bufio.NewScanner(c.in)
+	for scanner.Scan() {
+		c.lines <- strings.ToLower(strings.TrimSpace(scanner.Text()))
+	}
+	close(c.lines)
+}
+
+func (c *CLI) Approve(ctx context.Context, req agent.ApprovalRequest) (agent.Decision, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.once.Do(func() { go c.readLines() })
+
+	// Discard input typed while no question was pending.
+	for drained := false; !drained; {
+		select {
+		case _, ok := <-c.lines:
+			drained = !ok
+		default:
+			drained = true
+		}
+	}
+
+	fmt.Fprintf(c.out, "\nApproval required for %s(%s)\n", req.Tool, formatArgs(req))
+	if req.Dangerous != "" {
+		fmt.Fprintf(c.out, "  Dangerous: %s\n", req.Dangerous)
+	}
+	for _, effect := range req.SideEffects {
+		fmt.Fprintf(c.out, "  Side effect: %s\n", effect)
+	}
+	fmt.Fprintf(c.out, "  Request: %s\n", req.Request)
+	fmt.Fprint(c.out, "Approve? [y/N] ")
+
+	select {
+	case <-ctx.Done():
+		return agent.Decision{Approver: "cli"}, ctx.Err()
+	case line, ok := <-c.lines:
+		if !ok {
+			return agent.Decision{Approver: "cli"}, io.ErrUnexpectedEOF
+		}
+		decision := agent.Decision{Approver: "cli", Time: time.Now()}
+		if line == "y" || line == "yes" {
+			decision.Approved = true
+		} else {
+			decision.Reason = "denied at the prompt"
+		}
+		return decision, nil
+	}
+}
+
+func formatArgs(req agent.ApprovalRequest) string {
+	args := make([]string, len(req.Args))
+	for i, arg := range req.Args {
+		args[i] = fmt.Sprintf("%s=%v", arg.Name, arg.Value)
+	}
+	return strings.Join(args, ", ")
+}
+
+// HTTP posts each agent.ApprovalRequest as JSON to a callback URL and expects
+// a JSON agent.Decision in reply, e.g. {"approved": true, "reason": "ok"}.
+// Any status other than 200 is treated as an error, which denies the call.
+type HTTP struct {
+	URL    string
+	Client *http.Client // http.DefaultClient when nil
+}
+
+// NewHTTP creates an HTTP approver for the callback URL.
+func NewHTTP(url string) *HTTP {
+	return &HTTP{URL: url}
+}
+
+func (h *HTTP) Approve(ctx context.Context, req agent.ApprovalRequest) (agent.Decision, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return agent.Decision{Approver: h.URL}, fmt.Errorf("error encoding approval request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return agent.Decision{Approver: h.URL}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return agent.Decision{Approver: h.URL}, fmt.Errorf("approval callback failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return agent.Decision{Approver: h.URL}, fmt.Errorf("approval callback returned %s", resp.Status)
+	}
+
+	var decision agent.Decision
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return agent.Decision{Approver: h.URL}, fmt.Errorf("invalid approval decision: %w", err)
+	}
+	if decision.Approver == "" {
+		decision.Approver = h.URL
+	}
+	return decision, nil
+}
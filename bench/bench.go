@@ -0,0 +1,197 @@
+// Package bench measures how well an agent picks and calls tools on a
+// dataset of requests with known answers.
+package bench
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-agent/agent"
+	"go-agent/recording"
+	"go-agent/tools/doctest"
+	"os"
+	"strings"
+	"time"
+)
+
+// Case is one line of a JSONL dataset. Result may be a single value, a list
+// of values, or an object keyed by result name such as
+// {"quotient": 3, "remainder": 2}. Error is the expected tool error; when
+// neither is given, any successful call counts as a success. A null Function
+// expects the agent to decline because no tool applies.
+type Case struct {
+	Request   string `json:"request"`
+	Function  string `json:"function"`
+	Arguments []any  `json:"arguments,omitempty"`
+	Result    any    `json:"result,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// CaseResult is the outcome of running one case.
+type CaseResult struct {
+	Case          Case                `json:"case"`
+	Call          *agent.FunctionCall `json:"call,omitempty"`
+	Result        any                 `json:"result,omitempty"`
+	Error         string              `json:"error,omitempty"`
+	FunctionMatch bool                `json:"function_match"`
+	ArgumentMatch bool                `json:"argument_match"`
+	Success       bool                `json:"success"`          // The outcome matched the expected result or error
+	Reason        string              `json:"reason,omitempty"` // Why the case did not succeed
+	Latency       time.Duration       `json:"latency"`
+	FirstToken    time.Duration       `json:"first_token"`
+	Retries       int                 `json:"retries"`
+}
+
+// LoadDataset reads a JSONL dataset, skipping blank lines. Numbers are kept
+// as json.Number so that expected arguments keep their exact form.
+func LoadDataset(path string) ([]Case, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var cases []Case
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(text))
+		decoder.UseNumber()
+
+		var c Case
+		if err := decoder.Decode(&c); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if c.Request == "" {
+			return nil, fmt.Errorf("%s:%d: missing request", path, line)
+		}
+		cases = append(cases, c)
+	}
+
+	return cases, scanner.Err()
+}
+
+// Run sends every case through the agent and scores the outcomes. The label
+// identifies the model and prompt in reports.
+func Run(ctx context.Context, a *agent.Agent, label string, cases []Case) (Report, error) {
+	report := Report{Label: label, Started: time.Now()}
+
+	for _, c := range cases {
+		t, err := recording.Capture(ctx, a, c.Request)
+		if err != nil {
+			return report, err
+		}
+		report.Cases = append(report.Cases, score(c, t))
+	}
+
+	report.Summary = summarize(report.Cases)
+	return report, nil
+}
+
+// score compares a recorded run with the expectations of its case.
+func score(c Case, t *recording.Trace) CaseResult {
+	result := CaseResult{Case: c, Call: t.Call(), Latency: t.Duration, Retries: len(t.Retries)}
+	for _, g := range t.Generations {
+		if g.Phase == agent.PhaseCall {
+			result.FirstToken = g.FirstToken
+			break
+		}
+	}
+
+	if result.Call != nil {
+		result.FunctionMatch = baseName(result.Call.Function) == baseName(c.Function)
+		result.ArgumentMatch = result.FunctionMatch && argumentsMatch(result.Call.Arguments, c.Arguments)
+	}
+
+	switch {
+	case t.Error != "":
+		result.Error = t.Error
+		result.Reason = "request failed: " + t.Error
+	case result.Call != nil && result.Call.Declined():
+		if c.Function != "" {
+			result.Reason = "no tool was called: " + result.Call.Answer
+		}
+	case t.Tool == nil:
+		result.Reason = "no tool was run"
+	case t.Tool.Error != "":
+		result.Error = t.Tool.Error
+		if c.Error == "" {
+			result.Reason = "unexpected error: " + t.Tool.Error
+		} else if !strings.Contains(t.Tool.Error, c.Error) {
+			result.Reason = fmt.Sprintf("expected error %q, got %q", c.Error, t.Tool.Error)
+		}
+	default:
+		result.Result = t.Tool.Result
+		if c.Error != "" {
+			result.Reason = fmt.Sprintf("expected error %q, got result %s", c.Error, t.Tool.Result)
+		} else if c.Result != nil && !resultMatches(t.Tool.Result.Slice(), c.Result, t.Tool.Result.Get) {
+			result.Reason = fmt.Sprintf("expected result %s, got %s", toJSON(c.Result), t.Tool.Result)
+		}
+	}
+
+	if result.Reason == "" && !result.FunctionMatch {
+		expected := c.Function
+		if expected == "" {
+			expected = "null"
+		}
+		result.Reason = fmt.Sprintf("expected function %s, got %s", expected, result.Call.Function)
+	}
+	result.Success = result.Reason == ""
+	return result
+}
+
+// baseName strips type arguments, so that "Max[int]" matches "Max".
+func baseName(function string) string {
+	if i := strings.IndexByte(function, '['); i >= 0 {
+		return function[:i]
+	}
+	return function
+}
+
+func argumentsMatch(got, expected []any) bool {
+	if expected == nil {
+		return true
+	}
+	if len(got) != len(expected) {
+		return false
+	}
+	for i := range got {
+		if !doctest.Equal(got[i], expected[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// resultMatches compares the tool's values with the expected result, which
+// may be a single value, a list of values, or an object keyed by name.
+func resultMatches(got []any, expected any, get func(string) (any, bool)) bool {
+	switch expected := expected.(type) {
+	case map[string]any:
+		for name, want := range expected {
+			value, ok := get(name)
+			if !ok || !doctest.Equal(value, want) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		return argumentsMatch(got, expected)
+	default:
+		return len(got) == 1 && doctest.Equal(got[0], expected)
+	}
+}
+
+func toJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,178 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report is the outcome of a benchmark run.
+type Report struct {
+	Label   string       `json:"label"`
+	Started time.Time    `json:"started"`
+	Summary Summary      `json:"summary"`
+	Cases   []CaseResult `json:"cases"`
+}
+
+// Summary holds the aggregate scores of a run. Rates are between 0 and 1.
+type Summary struct {
+	Total            int         `json:"total"`
+	FunctionAccuracy float64     `json:"function_accuracy"`
+	ArgumentMatch    float64     `json:"argument_match"`
+	ExecutionSuccess float64     `json:"execution_success"`
+	Retries          int         `json:"retries"`
+	Latency          Percentiles `json:"latency"`
+	FirstToken       Percentiles `json:"first_token"`
+}
+
+// Percentiles summarises a latency distribution.
+type Percentiles struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P95 time.Duration `json:"p95"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+func summarize(cases []CaseResult) Summary {
+	summary := Summary{Total: len(cases)}
+	if len(cases) == 0 {
+		return summary
+	}
+
+	var functions, arguments, successes int
+	latencies := make([]time.Duration, len(cases))
+	firstTokens := make([]time.Duration, len(cases))
+	for i, c := range cases {
+		if c.FunctionMatch {
+			functions++
+		}
+		if c.ArgumentMatch {
+			arguments++
+		}
+		if c.Success {
+			successes++
+		}
+		summary.Retries += c.Retries
+		latencies[i] = c.Latency
+		firstTokens[i] = c.FirstToken
+	}
+
+	total := float64(len(cases))
+	summary.FunctionAccuracy = float64(functions) / total
+	summary.ArgumentMatch = float64(arguments) / total
+	summary.ExecutionSuccess = float64(successes) / total
+	summary.Latency = percentiles(latencies)
+	summary.FirstToken = percentiles(firstTokens)
+	return summary
+}
+
+// percentiles computes nearest-rank percentiles of the durations.
+func percentiles(durations []time.Duration) Percentiles {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	rank := func(p float64) time.Duration {
+		i := int(p*float64(len(sorted))+0.999999) - 1
+		return sorted[max(0, min(i, len(sorted)-1))]
+	}
+
+	return Percentiles{P50: rank(0.50), P90: rank(0.90), P95: rank(0.95), P99: rank(0.99), Max: sorted[len(sorted)-1]}
+}
+
+// Failures returns the cases that did not succeed.
+func (r Report) Failures() []CaseResult {
+	var failed []CaseResult
+	for _, c := range r.Cases {
+		if !c.Success {
+			failed = append(failed, c)
+		}
+	}
+	return failed
+}
+
+// WriteJSON writes the report to path.
+func (r Report) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadReport reads a report written by WriteJSON.
+func LoadReport(path string) (Report, error) {
+	var r Report
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("invalid report %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Markdown renders the reports as a comparison table with one row per
+// report, followed by the failed cases of each.
+func Markdown(reports ...Report) string {
+	var b strings.Builder
+
+	b.WriteString("| Label | Cases | Function accuracy | Argument match | Execution success | Retries | Latency p50 | p90 | p99 | First token p50 |\n")
+	b.WriteString("|---|---:|---:|---:|---:|---:|---:|---:|---:|---:|\n")
+	for _, r := range reports {
+		s := r.Summary
+		fmt.Fprintf(&b, "| %s | %d | %s | %s | %s | %d | %s | %s | %s | %s |\n",
+			r.Label, s.Total, percent(s.FunctionAccuracy), percent(s.ArgumentMatch), percent(s.ExecutionSuccess), s.Retries,
+			milliseconds(s.Latency.P50), milliseconds(s.Latency.P90), milliseconds(s.Latency.P99), milliseconds(s.FirstToken.P50))
+	}
+
+	for _, r := range reports {
+		failed := r.Failures()
+		if len(failed) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "\n### Failures: %s\n\n", r.Label)
+		b.WriteString("| Request | Expected | Got | Reason |\n")
+		b.WriteString("|---|---|---|---|\n")
+		for _, c := range failed {
+			got := ""
+			if c.Call != nil {
+				got = formatCall(c.Call.Function, c.Call.Arguments)
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n",
+				escape(c.Case.Request), formatCall(c.Case.Function, c.Case.Arguments), escape(got), escape(c.Reason))
+		}
+	}
+
+	return b.String()
+}
+
+// formatCall renders a call as "Add(3, 4)".
+func formatCall(function string, args []any) string {
+	if function == "" {
+		return "null"
+	}
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		parts[i] = toJSON(arg)
+	}
+	return fmt.Sprintf("%s(%s)", function, strings.Join(parts, ", "))
+}
+
+func percent(rate float64) string {
+	return fmt.Sprintf("%.1f%%", rate*100)
+}
+
+func milliseconds(d time.Duration) string {
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
+
+// escape keeps a value from breaking the table layout.
+func escape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
@@ -2,10 +2,13 @@
 package calculator
 
 import (
+	"cmp"
 	"errors"
 	"math"
 )
 
+// FunctionRegistry returns the calculator functions by name. A generic
+// function is given as a map from type arguments to its instantiations.
 func FunctionRegistry() map[string]interface{} {
 	return map[string]interface{}{
 		"Add":        Add,
@@ -22,6 +25,15 @@ func FunctionRegistry() map[string]interface{} {
 		"Log":        Log,
 		"Log10":      Log10,
 		"Sum":        Sum,
+		"DivMod":     DivMod,
+		"Max": map[string]interface{}{
+			"int":     Max[int],
+			"float64": Max[float64],
+		},
+		"Min": map[string]interface{}{
+			"int":     Min[int],
+			"float64": Min[float64],
+		},
 	}
 }
 
@@ -29,7 +41,8 @@ func FunctionRegistry() map[string]interface{} {
 // @param a: The first number.
 // @param b: The second number.
 // @return float64: The sum of a and b.
-// @example: Add(3, 4) // returns 7
+// @example "What is 3 plus 4?": Add(3, 4) // returns 7
+// @pure
 func Add(a, b float64) float64 {
 	return a + b
 }
@@ -38,7 +51,8 @@ func Add(a, b float64) float64 {
 // @param a: The first number.
 // @param b: The second number.
 // @return float64: The difference between a and b.
-// @example: Subtract(10, 4) // returns 6
+// @example "Take 4 away from 10.": Subtract(10, 4) // returns 6
+// @pure
 func Subtract(a, b float64) float64 {
 	return a - b
 }
@@ -47,7 +61,8 @@ func Subtract(a, b float64) float64 {
 // @param a: The first number.
 // @param b: The second number.
 // @return float64: The product of a and b.
-// @example: Multiply(3, 4) // returns 12
+// @example "What is 3 times 4?": Multiply(3, 4) // returns 12
+// @pure
 func Multiply(a, b float64) float64 {
 	return a * b
 }
@@ -57,7 +72,11 @@ func Multiply(a, b float64) float64 {
 // @param b: The divisor.
 // @return float64: The quotient of a divided by b.
 // @constraint b != 0: b must not be zero.
-// @example: Divide(10, 2) // returns 5
+// @errors: Returns an error when b is zero.
+// @example "What is 10 divided by 2?": Divide(10, 2) // returns 5
+// @example: Divide(1, 0) // error: division by zero is not allowed
+// @see: Modulus
+// @pure
 func Divide(a, b float64) (float64, error) {
 	if b == 0 {
 		return 0, errors.New("division by zero is not allowed")
@@ -69,28 +88,35 @@ func Divide(a, b float64) (float64, error) {
 // @param x: The number to calculate the square root of.
 // @return float64: The square root of the input number.
 // @constraint x >= 0: x must be non-negative.
-// @example: SquareRoot(4) // returns 2
-func SquareRoot(a float64) (float64, error) {
-	if a < 0 {
+// @example "Find the square root of 4.": SquareRoot(4) // returns 2
+// @pure
+func SquareRoot(x float64) (float64, error) {
+	if x < 0 {
 		return 0, errors.New("square root of a negative number is not allowed")
 	}
-	return math.Sqrt(a), nil
+	return math.Sqrt(x), nil
 }
 
 // Power returns the result of raising a to the power of b.
 // @param a: The base.
 // @param b: The exponent.
 // @return float64: The result of a raised to the power of b.
-// @example: Power(2, 3) // returns 8
+// @example "What is 2 cubed?": Power(2, 3) // returns 8
+// @pure
 func Power(a, b float64) float64 {
 	return math.Pow(a, b)
 }
 
 // Factorial calculates the factorial of a non-negative integer.
+//
+// The result is returned as a float64 so that values beyond the range of
+// int64 can still be represented, at the cost of precision.
 // @param n: The number to calculate the factorial of.
 // @return float64: The factorial of the input number.
 // @constraint n >= 0: n must be non-negative.
-// @example: Factorial(5) // returns 120
+// @errors: Returns an error when n is negative.
+// @example "Compute 5 factorial.": Factorial(5) // returns 120
+// @pure
 func Factorial(n int) (float64, error) {
 	if n < 0 {
 		return 0, errors.New("factorial of a negative number is not allowed")
@@ -107,7 +133,8 @@ func Factorial(n int) (float64, error) {
 // @param b: The divisor.
 // @return float64: The remainder of a divided by b.
 // @constraint b != 0: b must not be zero.
-// @example: Modulus(10, 3) // returns 1
+// @example "What is the remainder of 10 divided by 3?": Modulus(10, 3) // returns 1
+// @pure
 func Modulus(a, b float64) (float64, error) {
 	if b == 0 {
 		return 0, errors.New("division by zero is not allowed")
@@ -117,24 +144,30 @@ func Modulus(a, b float64) (float64, error) {
 
 // Sin calculates the sine of a number in radians.
 // @param x: The angle in radians.
+// @unit x: radians
 // @return float64: The sine of the input angle.
 // @example: Sin(math.Pi / 2) // returns 1
+// @pure
 func Sin(x float64) float64 {
 	return math.Sin(x)
 }
 
 // Cos calculates the cosine of a number in radians.
 // @param x: The angle in radians.
+// @unit x: radians
 // @return float64: The cosine of the input angle.
 // @example: Cos(0) // returns 1
+// @pure
 func Cos(x float64) float64 {
 	return math.Cos(x)
 }
 
 // Tan calculates the tangent of a number in radians.
 // @param x: The angle in radians.
+// @unit x: radians
 // @return float64: The tangent of the input angle.
 // @example: Tan(math.Pi / 4) // returns 1
+// @pure
 func Tan(x float64) float64 {
 	return math.Tan(x)
 }
@@ -144,6 +177,8 @@ func Tan(x float64) float64 {
 // @return float64: The natural logarithm of the input number.
 // @constraint x > 0: x must be positive.
 // @example: Log(2.71828) // returns 1
+// @see: Log10
+// @pure
 func Log(x float64) (float64, error) {
 	if x <= 0 {
 		return 0, errors.New("logarithm of a non-positive number is not allowed")
@@ -156,6 +191,8 @@ func Log(x float64) (float64, error) {
 // @return float64: The base-10 logarithm of the input number.
 // @constraint x > 0: x must be positive.
 // @example: Log10(100) // returns 2
+// @see: Log
+// @pure
 func Log10(x float64) (float64, error) {
 	if x <= 0 {
 		return 0, errors.New("logarithm of a non-positive number is not allowed")
@@ -163,10 +200,28 @@ func Log10(x float64) (float64, error) {
 	return math.Log10(x), nil
 }
 
+// DivMod performs integer division, returning both the quotient and the remainder.
+// @param a: The dividend.
+// @param b: The divisor.
+// @return quotient int: The integer quotient of a divided by b.
+// @return remainder int: The remainder of a divided by b.
+// @constraint b != 0: b must not be zero.
+// @errors: Returns an error when b is zero.
+// @example "What are the quotient and remainder of 17 divided by 5?": DivMod(17, 5) // returns 3, 2
+// @see: Divide, Modulus
+// @pure
+func DivMod(a, b int) (quotient, remainder int, err error) {
+	if b == 0 {
+		return 0, 0, errors.New("division by zero is not allowed")
+	}
+	return a / b, a % b, nil
+}
+
 // Sum returns the sum of a variadic list of numbers.
 // @param numbers: A variadic list of numbers to sum.
 // @return float64: The sum of all input numbers.
-// @example: Sum(1, 2, 3, 4, 5) // returns 15
+// @example "Add up 1, 2, 3, 4 and 5.": Sum(1, 2, 3, 4, 5) // returns 15
+// @pure
 func Sum(numbers ...float64) float64 {
 	total := 0.0
 	for _, num := range numbers {
@@ -174,3 +229,25 @@ func Sum(numbers ...float64) float64 {
 	}
 	return total
 }
+
+// Max returns the larger of two values.
+// @param a: The first value.
+// @param b: The second value.
+// @return T: The larger of a and b.
+// @example "Which is bigger, 3 or 7?": Max(3, 7) // returns 7
+// @see: Min
+// @pure
+func Max[T cmp.Ordered](a, b T) T {
+	return max(a, b)
+}
+
+// Min returns the smaller of two values.
+// @param a: The first value.
+// @param b: The second value.
+// @return T: The smaller of a and b.
+// @example "Which is smaller, 2.5 or 1.5?": Min(2.5, 1.5) // returns 1.5
+// @see: Max
+// @pure
+func Min[T cmp.Ordered](a, b T) T {
+	return min(a, b)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go-agent/agent"
+	"go-agent/bench"
+	"go-agent/calculator"
+	"go-agent/llm"
+	"go-agent/tools/toolstore"
+	"os"
+)
+
+func main() {
+	dataset := flag.String("dataset", "bench/datasets/calculator.jsonl", "JSONL dataset of requests and expected calls")
+	model := flag.String("model", "llama3.1:8b", "Ollama model to benchmark")
+	llamaCpp := flag.String("llamacpp", "", "URL of a llama.cpp server to use instead of Ollama")
+	promptFile := flag.String("prompt", "", "file with a prompt template to use instead of the default")
+	label := flag.String("label", "", "name of the run in reports (default: the model)")
+	retries := flag.Int("retries", 0, "times to ask the LLM again after an unusable reply")
+	jsonOut := flag.String("json", "", "file to write the JSON report to")
+	markdownOut := flag.String("markdown", "", "file to write the markdown report to")
+	compare := flag.Bool("compare", false, "print a markdown comparison of the JSON reports given as arguments instead of running")
+	cacheDir := flag.String("cache", "", "directory caching LLM replies; cached replies make latencies meaningless")
+	cacheTTL := flag.Duration("cache-ttl", 0, "how long a cached LLM reply is used; forever when 0")
+	refresh := flag.Bool("refresh", false, "query the LLM even for cached replies, and cache the fresh ones")
+	flag.Parse()
+
+	if *compare {
+		var reports []bench.Report
+		for _, path := range flag.Args() {
+			report, err := bench.LoadReport(path)
+			if err != nil {
+				fmt.Printf("Error loading report: %v\n", err)
+				os.Exit(1)
+			}
+			reports = append(reports, report)
+		}
+		fmt.Print(bench.Markdown(reports...))
+		return
+	}
+
+	cases, err := bench.LoadDataset(*dataset)
+	if err != nil {
+		fmt.Printf("Error loading dataset: %v\n", err)
+		os.Exit(1)
+	}
+
+	var engine agent.LLMEngine
+	if *llamaCpp != "" {
+		engine = llm.NewLlamaCppEngine(*llamaCpp)
+	} else {
+		ollamaEngine, err := llm.NewOllamaEngine(*model)
+		if err != nil {
+			fmt.Printf("Error initializing LLM engine: %v\n", err)
+			os.Exit(1)
+		}
+		engine = ollamaEngine
+	}
+	if *cacheDir != "" {
+		engine, err = llm.NewCachingEngine(engine, *cacheDir, llm.WithCacheTTL(*cacheTTL))
+		if err != nil {
+			fmt.Printf("Error initializing LLM cache: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *label == "" {
+		*label = *model
+		if *llamaCpp != "" {
+			*label = *llamaCpp
+		}
+	}
+
+	toolStore, err := toolstore.NewFunctionStoreFromPkg("go-agent/calculator", calculator.FunctionRegistry(), nil)
+	if err != nil {
+		fmt.Printf("Error creating function store: %v\n", err)
+		os.Exit(1)
+	}
+
+	goDeveloper := agent.NewAgent(engine, toolStore)
+	goDeveloper.MaxRetries = *retries
+	if *promptFile != "" {
+		prompt, err := os.ReadFile(*promptFile)
+		if err != nil {
+			fmt.Printf("Error reading prompt: %v\n", err)
+			os.Exit(1)
+		}
+		goDeveloper.Prompt = string(prompt)
+		if err := goDeveloper.Validate(); err != nil {
+			fmt.Printf("Error in prompt: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	ctx := context.Background()
+	if *refresh {
+		ctx = llm.BypassCache(ctx)
+	}
+
+	report, err := bench.Run(ctx, goDeveloper, *label, cases)
+	if err != nil {
+		fmt.Printf("Error running benchmark: %v\n", err)
+		os.Exit(1)
+	}
+
+	markdown := bench.Markdown(report)
+	fmt.Print(markdown)
+
+	if *jsonOut != "" {
+		if err := report.WriteJSON(*jsonOut); err != nil {
+			fmt.Printf("Error writing JSON report: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *markdownOut != "" {
+		if err := os.WriteFile(*markdownOut, []byte(markdown), 0o644); err != nil {
+			fmt.Printf("Error writing markdown report: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
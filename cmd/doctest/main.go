@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"go-agent/calculator"
+	"go-agent/tools/doctest"
+	"go-agent/tools/toolstore"
+	"os"
+)
+
+func main() {
+	store, err := toolstore.NewFunctionStoreFromPkg("go-agent/calculator", calculator.FunctionRegistry(), nil)
+	if err != nil {
+		fmt.Printf("Error creating function store: %v\n", err)
+		os.Exit(1)
+	}
+
+	report := doctest.Run(store)
+	for _, result := range report.Results {
+		switch {
+		case result.Skipped:
+			fmt.Printf("SKIP %s: %s\n", result.Tool, result.Example.Raw)
+		case result.Passed:
+			fmt.Printf("PASS %s: %s\n", result.Tool, result.Example.Raw)
+		default:
+			fmt.Printf("FAIL %s: %s\n     %s\n", result.Tool, result.Example.Raw, result.Reason)
+		}
+	}
+
+	failed := report.Failed()
+	fmt.Printf("%d example(s), %d failed\n", len(report.Results), len(failed))
+	if len(failed) > 0 {
+		os.Exit(1)
+	}
+}
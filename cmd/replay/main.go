@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go-agent/agent"
+	"go-agent/calculator"
+	"go-agent/llm"
+	"go-agent/recording"
+	"go-agent/tools/toolstore"
+	"os"
+)
+
+func main() {
+	model := flag.String("model", "llama3.1:8b", "Ollama model to replay against")
+	llamaCpp := flag.String("llamacpp", "", "URL of a llama.cpp server to use instead of Ollama")
+	promptFile := flag.String("prompt", "", "file with a prompt template to use instead of the default")
+	answer := flag.Bool("answer", false, "synthesize a natural-language answer")
+	outDir := flag.String("out", "", "directory to write the new traces to")
+	showPrompts := flag.Bool("show-prompts", false, "print changed prompts in full")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] trace.json...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var engine agent.LLMEngine
+	if *llamaCpp != "" {
+		engine = llm.NewLlamaCppEngine(*llamaCpp)
+	} else {
+		ollamaEngine, err := llm.NewOllamaEngine(*model)
+		if err != nil {
+			fmt.Printf("Error initializing LLM engine: %v\n", err)
+			os.Exit(1)
+		}
+		engine = ollamaEngine
+	}
+
+	toolStore, err := toolstore.NewFunctionStoreFromPkg("go-agent/calculator", calculator.FunctionRegistry(), nil)
+	if err != nil {
+		fmt.Printf("Error creating function store: %v\n", err)
+		os.Exit(1)
+	}
+
+	goDeveloper := agent.NewAgent(engine, toolStore)
+	goDeveloper.SynthesizeAnswer = *answer
+	if *answer && *llamaCpp == "" {
+		// The answer is plain text, so it must not be generated in JSON mode
+		answerEngine, err := llm.NewOllamaEngine(*model, llm.WithFormat(""))
+		if err != nil {
+			fmt.Printf("Error initializing LLM engine: %v\n", err)
+			os.Exit(1)
+		}
+		goDeveloper.AnswerEngine = answerEngine
+	}
+	if *promptFile != "" {
+		prompt, err := os.ReadFile(*promptFile)
+		if err != nil {
+			fmt.Printf("Error reading prompt: %v\n", err)
+			os.Exit(1)
+		}
+		goDeveloper.Prompt = string(prompt)
+		if err := goDeveloper.Validate(); err != nil {
+			fmt.Printf("Error in prompt: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	ctx := context.Background()
+	changed := 0
+	for _, path := range flag.Args() {
+		recorded, err := recording.Load(path)
+		if err != nil {
+			fmt.Printf("Error loading trace: %v\n", err)
+			os.Exit(1)
+		}
+
+		replayed, err := recording.Replay(ctx, goDeveloper, recorded)
+		if err != nil {
+			fmt.Printf("Error replaying %s: %v\n", path, err)
+			os.Exit(1)
+		}
+		if *outDir != "" {
+			if _, err := recording.Write(*outDir, replayed); err != nil {
+				fmt.Printf("Error writing trace: %v\n", err)
+			}
+		}
+
+		diffs := recording.Diff(recorded, replayed)
+		if len(diffs) == 0 {
+			fmt.Printf("SAME    %s: %s\n", path, recorded.Request)
+			continue
+		}
+
+		changed++
+		fmt.Printf("CHANGED %s: %s\n", path, recorded.Request)
+		for _, diff := range diffs {
+			if diff.Field == "prompt" && !*showPrompts {
+				fmt.Printf("  prompt: changed (%d -> %d bytes)\n", len(diff.Old), len(diff.New))
+				continue
+			}
+			fmt.Printf("  %s:\n    - %s\n    + %s\n", diff.Field, diff.Old, diff.New)
+		}
+	}
+
+	fmt.Printf("%d trace(s), %d changed\n", flag.NArg(), changed)
+	if changed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"go-agent/agent"
+	"go-agent/approval"
+	"go-agent/calculator"
+	"go-agent/llm"
+	"go-agent/policy"
+	"go-agent/prompt"
+	"go-agent/recording"
+	"go-agent/server"
+	"go-agent/telemetry"
+	"go-agent/tools/evaluation"
+	"go-agent/tools/sandbox"
+	"go-agent/tools/toolstore"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+func main() {
+	if sandbox.IsWorker() {
+		sandbox.Serve(calculator.FunctionRegistry())
+	}
+
+	addr := flag.String("addr", ":8080", "address to listen on")
+	model := flag.String("model", "llama3.1:8b", "Ollama model to use")
+	retries := flag.Int("retries", 1, "times to ask the LLM again after an unusable reply")
+	answer := flag.Bool("answer", true, "synthesize a natural-language answer")
+	promptDir := flag.String("prompts", "", "directory of <name>/<version>.tmpl prompt templates")
+	callPrompt := flag.String("call-prompt", prompt.Call, "call template to use, e.g. call@v2")
+	answerPrompt := flag.String("answer-prompt", prompt.Answer, "answer template to use, e.g. answer@v1")
+	approvalURL := flag.String("approval-url", "", "callback URL asked to approve side-effecting tools; they are denied when empty")
+	policyFile := flag.String("policy", "", "YAML or JSON policy controlling which callers may use which tools")
+	traceDir := flag.String("trace-dir", "", "directory to write a JSON trace of every request to")
+	sandboxed := flag.Bool("sandbox", false, "run tools in a separate worker process")
+	toolTimeout := flag.Duration("tool-timeout", 10*time.Second, "wall-clock limit per tool call")
+	toolConcurrency := flag.Int("tool-concurrency", 0, "calls of the same tool allowed to run at once; unlimited when 0")
+	toolMemory := flag.Uint64("tool-memory", 1<<30, "address space limit of the sandbox worker in bytes")
+	cacheSize := flag.Int("cache-size", 1000, "results of pure tools to cache; caching is disabled when 0")
+	cacheTTL := flag.Duration("cache-ttl", 10*time.Minute, "how long a cached result is kept")
+	replicas := flag.String("replicas", "", "comma-separated Ollama server URLs to spread requests over")
+	strategy := flag.String("strategy", "priority", "how to choose among -replicas: priority, round-robin or least-loaded")
+	flag.Parse()
+
+	// Export traces when an OTLP endpoint is configured
+	ctx := context.Background()
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+		shutdown, err := telemetry.SetupOTLP(ctx, "go-agent")
+		if err != nil {
+			fmt.Printf("Error setting up tracing: %v\n", err)
+			os.Exit(1)
+		}
+		defer shutdown(ctx)
+	}
+
+	engine, answerEngine, err := newEngines(*model, *replicas, *strategy)
+	if err != nil {
+		fmt.Printf("Error initializing LLM engine: %v\n", err)
+		os.Exit(1)
+	}
+
+	toolStore, err := toolstore.NewFunctionStoreFromPkg("go-agent/calculator", calculator.FunctionRegistry(), nil)
+	if err != nil {
+		fmt.Printf("Error creating function store: %v\n", err)
+		os.Exit(1)
+	}
+
+	toolStore.SetDefaultPolicy(toolstore.ExecutionPolicy{
+		Timeout:          *toolTimeout,
+		MaxConcurrent:    *toolConcurrency,
+		BreakerThreshold: 5,
+		BreakerCooldown:  30 * time.Second,
+	})
+
+	if *cacheSize > 0 {
+		toolStore.SetCache(evaluation.NewCache(*cacheSize, *cacheTTL))
+	}
+
+	goDeveloper := agent.NewAgent(engine, toolStore)
+	goDeveloper.MaxRetries = *retries
+	goDeveloper.SynthesizeAnswer = *answer
+	goDeveloper.AnswerEngine = answerEngine
+	if *policyFile != "" {
+		toolPolicy, err := policy.Load(*policyFile)
+		if err != nil {
+			fmt.Printf("Error loading policy: %v\n", err)
+			os.Exit(1)
+		}
+		goDeveloper.Authorizer = toolPolicy
+	}
+
+	goDeveloper.Approver = approval.AutoDeny("no approval callback is configured")
+	if *approvalURL != "" {
+		goDeveloper.Approver = approval.NewHTTP(*approvalURL)
+	}
+
+	if *sandboxed {
+		tools := sandbox.New(sandbox.Config{Timeout: *toolTimeout, MemoryLimit: *toolMemory})
+		defer tools.Close()
+		goDeveloper.Executor = tools
+	}
+
+	registry := prompt.Default()
+	if *promptDir != "" {
+		registry, err = prompt.Load(*promptDir)
+		if err != nil {
+			fmt.Printf("Error loading prompts: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := goDeveloper.UsePrompts(registry, *callPrompt, *answerPrompt); err != nil {
+		fmt.Printf("Error in prompts: %v\n", err)
+		os.Exit(1)
+	}
+
+	metrics := prometheus.NewRegistry()
+	metrics.MustRegister(collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	goDeveloper.Subscribe(telemetry.NewMetrics(metrics))
+	if *traceDir != "" {
+		goDeveloper.Subscribe(recording.NewRecorder(recording.SaveToDir(*traceDir, nil)))
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: server.New(goDeveloper, metrics)}
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		signals, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		<-signals.Done()
+
+		// Stop the generations first, so that the requests waiting on them
+		// finish instead of holding up the shutdown.
+		fmt.Printf("Shutting down, stopped %d generations\n", stopAll(engine, answerEngine))
+		shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			fmt.Printf("Error shutting down: %v\n", err)
+		}
+	}()
+
+	fmt.Printf("Listening on %s\n", *addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		fmt.Printf("Error serving: %v\n", err)
+		os.Exit(1)
+	}
+	<-stopped
+}
+
+// stopAll stops the generations in progress on the engines and returns how
+// many were stopped.
+func stopAll(engines ...agent.LLMEngine) int {
+	var stopped int
+	for _, engine := range engines {
+		if e, ok := engine.(interface{ StopAll() int }); ok {
+			stopped += e.StopAll()
+		}
+	}
+	return stopped
+}
+
+// newEngines creates the engines for function calls and for answers. With
+// replicas, each is a composite over one Ollama engine per server.
+func newEngines(model, replicas, strategyName string) (agent.LLMEngine, agent.LLMEngine, error) {
+	if replicas == "" {
+		engine, err := llm.NewOllamaEngine(model)
+		if err != nil {
+			return nil, nil, err
+		}
+		answerEngine, err := llm.NewOllamaEngine(model, llm.WithFormat(""))
+		if err != nil {
+			return nil, nil, err
+		}
+		return engine, answerEngine, nil
+	}
+
+	strategy, err := llm.ParseStrategy(strategyName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var backends, answerBackends []llm.Backend
+	for _, url := range strings.Split(replicas, ",") {
+		url = strings.TrimSpace(url)
+		engine, err := llm.NewOllamaEngine(model, llm.WithServerURL(url))
+		if err != nil {
+			return nil, nil, err
+		}
+		answerEngine, err := llm.NewOllamaEngine(model, llm.WithServerURL(url), llm.WithFormat(""))
+		if err != nil {
+			return nil, nil, err
+		}
+		backends = append(backends, llm.Backend{Name: url, Engine: engine})
+		answerBackends = append(answerBackends, llm.Backend{Name: url, Engine: answerEngine})
+	}
+
+	engine := llm.NewComposite(strategy, backends, llm.WithFirstTokenTimeout(30*time.Second))
+	answerEngine := llm.NewComposite(strategy, answerBackends, llm.WithFirstTokenTimeout(30*time.Second))
+	engine.StartHealthChecks(15 * time.Second)
+	answerEngine.StartHealthChecks(15 * time.Second)
+	return engine, answerEngine, nil
+}
@@ -0,0 +1,316 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-agent/tools/grammar"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Engine generates a stream of tokens for a prompt.
+type Engine interface {
+	GenerateTokens(ctx context.Context, prompt string) (*Stream, error)
+}
+
+// ConstrainedEngine generates tokens restricted to a grammar.
+type ConstrainedEngine interface {
+	Engine
+	GenerateConstrained(ctx context.Context, prompt string, g grammar.Grammar) (*Stream, error)
+}
+
+// Fingerprinter is implemented by engines that can describe the model and
+// options that determine their output, so that a cache does not mix up the
+// replies of different models.
+type Fingerprinter interface {
+	Fingerprint() string
+}
+
+// CachingEngine decorates an engine with a disk cache of completed token
+// streams, keyed by the engine's fingerprint, the grammar and the prompt. A
+// cached reply is replayed token by token as a stream, so that callers cannot
+// tell it from a live one. This only makes sense for deterministic engines,
+// e.g. at temperature 0.
+type CachingEngine struct {
+	engine      Engine
+	dir         string
+	fingerprint string
+	ttl         time.Duration
+	generations generations
+}
+
+// CacheOption configures a CachingEngine.
+type CacheOption func(*CachingEngine)
+
+// WithCacheTTL makes cached replies expire after ttl. By default they are
+// kept until removed.
+func WithCacheTTL(ttl time.Duration) CacheOption {
+	return func(c *CachingEngine) {
+		c.ttl = ttl
+	}
+}
+
+// WithFingerprint sets the description of the model and options used in the
+// cache key, overriding the engine's own Fingerprint.
+func WithFingerprint(fingerprint string) CacheOption {
+	return func(c *CachingEngine) {
+		c.fingerprint = fingerprint
+	}
+}
+
+type bypassKey struct{}
+
+// BypassCache returns a context under which a CachingEngine queries the
+// engine even when a reply is cached. The fresh reply replaces the cached one.
+func BypassCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+func bypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassKey{}).(bool)
+	return bypass
+}
+
+// NewCachingEngine creates a cache for engine in dir. The result also
+// implements ConstrainedEngine when engine does, so that constrained decoding
+// keeps working behind the cache.
+func NewCachingEngine(engine Engine, dir string, opts ...CacheOption) (Engine, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating cache directory: %w", err)
+	}
+
+	c := &CachingEngine{
+		engine:      engine,
+		dir:         dir,
+		fingerprint: fmt.Sprintf("%T", engine),
+	}
+	if f, ok := engine.(Fingerprinter); ok {
+		c.fingerprint = f.Fingerprint()
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if constrained, ok := engine.(ConstrainedEngine); ok {
+		return &constrainedCachingEngine{CachingEngine: c, constrained: constrained}, nil
+	}
+	return c, nil
+}
+
+// cacheEntry is the file stored for one reply.
+type cacheEntry struct {
+	Fingerprint string       `json:"fingerprint"`
+	Grammar     string       `json:"grammar,omitempty"` // Hash of the grammar, for constrained generations
+	Prompt      string       `json:"prompt"`
+	Created     time.Time    `json:"created"`
+	Tokens      []string     `json:"tokens"`
+	Reason      FinishReason `json:"finish_reason,omitempty"`
+	Usage       Usage        `json:"usage"`
+}
+
+func (c *CachingEngine) GenerateTokens(ctx context.Context, prompt string) (*Stream, error) {
+	return c.generate(ctx, prompt, "", func(ctx context.Context) (*Stream, error) {
+		return c.engine.GenerateTokens(ctx, prompt)
+	})
+}
+
+type constrainedCachingEngine struct {
+	*CachingEngine
+	constrained ConstrainedEngine
+}
+
+func (c *constrainedCachingEngine) GenerateConstrained(ctx context.Context, prompt string, g grammar.Grammar) (*Stream, error) {
+	encoded, err := json.Marshal(g)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding grammar: %w", err)
+	}
+	sum := sha256.Sum256(encoded)
+
+	return c.generate(ctx, prompt, hex.EncodeToString(sum[:]), func(ctx context.Context) (*Stream, error) {
+		return c.constrained.GenerateConstrained(ctx, prompt, g)
+	})
+}
+
+// generate replays the cached reply, or else streams a live one and stores it
+// once it completes.
+func (c *CachingEngine) generate(ctx context.Context, prompt, grammarHash string, live func(context.Context) (*Stream, error)) (*Stream, error) {
+	path := c.path(prompt, grammarHash)
+
+	ctx, gen := c.generations.start(ctx, prompt)
+
+	if !bypassed(ctx) {
+		if entry, ok := c.load(path); ok {
+			return c.replay(ctx, gen, entry), nil
+		}
+	}
+
+	source, err := live(ctx)
+	if err != nil {
+		c.generations.finish(gen)
+		return nil, err
+	}
+
+	stream, tokenChan := gen.stream()
+
+	go func() {
+		defer c.generations.finish(gen)
+
+		var tokens []string
+		for token := range source.Tokens {
+			tokens = append(tokens, token)
+			select {
+			case <-ctx.Done():
+				stream.Finish(StreamEnd{Err: ctx.Err()})
+				source.Drain()
+				return
+			case tokenChan <- token:
+				gen.token()
+			}
+		}
+
+		end := StreamEnd{Err: source.Err(), Reason: source.FinishReason(), Usage: source.Usage()}
+		stream.Finish(end)
+
+		// Only complete replies are worth replaying.
+		if end.Err != nil || end.Reason != FinishStop || len(tokens) == 0 {
+			return
+		}
+		entry := cacheEntry{
+			Fingerprint: c.fingerprint,
+			Grammar:     grammarHash,
+			Prompt:      prompt,
+			Created:     time.Now(),
+			Tokens:      tokens,
+			Reason:      end.Reason,
+			Usage:       end.Usage,
+		}
+		if err := c.store(path, entry); err != nil {
+			log.Printf("Error caching LLM reply: %v", err)
+		}
+	}()
+
+	return stream, nil
+}
+
+func (c *CachingEngine) replay(ctx context.Context, gen *generation, entry cacheEntry) *Stream {
+	stream, tokenChan := gen.stream()
+
+	go func() {
+		defer c.generations.finish(gen)
+
+		for _, token := range entry.Tokens {
+			select {
+			case <-ctx.Done():
+				stream.Finish(StreamEnd{Err: ctx.Err()})
+				return
+			case tokenChan <- token:
+				gen.token()
+			}
+		}
+		stream.Finish(StreamEnd{Reason: entry.Reason, Usage: entry.Usage})
+	}()
+
+	return stream
+}
+
+// StopGeneration stops a live or replayed generation by the ID of its stream.
+func (c *CachingEngine) StopGeneration(ctx context.Context, id GenerationID) error {
+	return c.generations.stop(id)
+}
+
+// ActiveGenerations describes the live and replayed generations in progress,
+// oldest first.
+func (c *CachingEngine) ActiveGenerations() []Generation {
+	return c.generations.list()
+}
+
+// StopAll stops every generation in progress and returns how many were
+// stopped.
+func (c *CachingEngine) StopAll() int {
+	return c.generations.stopAll()
+}
+
+// path returns the file of a reply, named after the hash of its key.
+func (c *CachingEngine) path(prompt, grammarHash string) string {
+	sum := sha256.Sum256([]byte(c.fingerprint + "\x00" + grammarHash + "\x00" + prompt))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// load reads a cached reply that has not expired.
+func (c *CachingEngine) load(path string) (cacheEntry, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cacheEntry{}, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		log.Printf("Ignoring corrupt LLM cache entry %s: %v", path, err)
+		return cacheEntry{}, false
+	}
+	if c.expired(entry) {
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+// store writes a reply through a temporary file, so that a concurrent reader
+// never sees a partial entry.
+func (c *CachingEngine) store(path string, entry cacheEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (c *CachingEngine) expired(entry cacheEntry) bool {
+	return c.ttl > 0 && time.Since(entry.Created) > c.ttl
+}
+
+// Prune removes the expired replies and returns how many were removed.
+func (c *CachingEngine) Prune() (int, error) {
+	files, err := os.ReadDir(c.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int
+	var errs []error
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(c.dir, file.Name())
+		if _, ok := c.load(path); ok {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	return removed, errors.Join(errs...)
+}
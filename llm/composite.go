@@ -0,0 +1,467 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-agent/tools/grammar"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAllEnginesFailed  = errors.New("all engines failed")
+	ErrFirstTokenTimeout = errors.New("timed out waiting for the first token")
+	ErrEmptyReply        = errors.New("engine returned an empty reply")
+)
+
+// Strategy decides in which order a Composite tries its engines.
+type Strategy int
+
+const (
+	Priority    Strategy = iota // The engines in the order given, e.g. a primary and its fallbacks
+	RoundRobin                  // Each generation starts at the next engine, for replicas
+	LeastLoaded                 // The engines with the fewest generations in progress first, for replicas
+)
+
+var strategyNames = map[Strategy]string{
+	Priority:    "priority",
+	RoundRobin:  "round-robin",
+	LeastLoaded: "least-loaded",
+}
+
+func (s Strategy) String() string {
+	return strategyNames[s]
+}
+
+// ParseStrategy parses "priority", "round-robin" or "least-loaded".
+func ParseStrategy(name string) (Strategy, error) {
+	for strategy, strategyName := range strategyNames {
+		if strategyName == name {
+			return strategy, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown strategy %q", name)
+}
+
+// Backend is an engine of a Composite.
+type Backend struct {
+	Name   string
+	Engine Engine
+}
+
+// HealthChecker is implemented by engines that can tell whether their server
+// is up.
+type HealthChecker interface {
+	Health(ctx context.Context) error
+}
+
+// EngineStats reports the use and health of one engine of a Composite.
+type EngineStats struct {
+	Name                string    `json:"name"`
+	Healthy             bool      `json:"healthy"`  // The last health check passed and the engine is not cooling down
+	Active              int       `json:"active"`   // Generations in progress
+	Requests            uint64    `json:"requests"` // Generations attempted
+	Failures            uint64    `json:"failures"` // Generations that failed, including timeouts
+	Timeouts            uint64    `json:"timeouts"` // Generations without a first token in time
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastFailure         time.Time `json:"last_failure,omitempty"`
+}
+
+// Composite is an engine that spreads generations over several engines and
+// falls back to the next one when an engine fails or does not produce a
+// first token in time. Once tokens have been streamed a generation cannot
+// move to another engine, so an error past that point ends the stream.
+//
+// An engine that fails repeatedly is put last for a cooldown period, as are
+// engines failing their health checks; they are still tried when every other
+// engine has failed.
+//
+// Composite always accepts constrained generations. Engines that do not
+// support them generate without the grammar.
+type Composite struct {
+	strategy          Strategy
+	backends          []*backend
+	firstTokenTimeout time.Duration
+	failureThreshold  int
+	cooldown          time.Duration
+
+	generations generations
+
+	mu         sync.Mutex
+	next       int // Next engine for RoundRobin
+	stopChecks context.CancelFunc
+}
+
+// CompositeOption configures a Composite.
+type CompositeOption func(*Composite)
+
+// WithFirstTokenTimeout moves on to the next engine when an engine produces no
+// token within timeout. There is no timeout by default.
+func WithFirstTokenTimeout(timeout time.Duration) CompositeOption {
+	return func(c *Composite) {
+		c.firstTokenTimeout = timeout
+	}
+}
+
+// WithFailureThreshold puts an engine last for cooldown after threshold
+// consecutive failures. The default is 3 failures and 30 seconds.
+func WithFailureThreshold(threshold int, cooldown time.Duration) CompositeOption {
+	return func(c *Composite) {
+		c.failureThreshold, c.cooldown = threshold, cooldown
+	}
+}
+
+// NewComposite creates an engine over the backends.
+func NewComposite(strategy Strategy, backends []Backend, opts ...CompositeOption) *Composite {
+	c := &Composite{
+		strategy:         strategy,
+		failureThreshold: 3,
+		cooldown:         30 * time.Second,
+	}
+	for _, b := range backends {
+		c.backends = append(c.backends, &backend{name: b.Name, engine: b.Engine, healthy: true})
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// GenerateTokens streams the reply of the first engine that produces one.
+func (c *Composite) GenerateTokens(ctx context.Context, prompt string) (*Stream, error) {
+	return c.generate(ctx, prompt, func(ctx context.Context, engine Engine) (*Stream, error) {
+		return engine.GenerateTokens(ctx, prompt)
+	})
+}
+
+// GenerateConstrained streams the constrained reply of the first engine that
+// produces one. Engines without constrained decoding generate freely.
+func (c *Composite) GenerateConstrained(ctx context.Context, prompt string, g grammar.Grammar) (*Stream, error) {
+	return c.generate(ctx, prompt, func(ctx context.Context, engine Engine) (*Stream, error) {
+		if constrained, ok := engine.(ConstrainedEngine); ok {
+			return constrained.GenerateConstrained(ctx, prompt, g)
+		}
+		return engine.GenerateTokens(ctx, prompt)
+	})
+}
+
+// attempt is a generation started on one engine.
+type attempt struct {
+	backend *backend
+	stream  *Stream
+	cancel  context.CancelFunc
+}
+
+// generate starts the generation on the first engine that accepts it, and
+// relays its tokens, falling back to the remaining engines in the background.
+func (c *Composite) generate(ctx context.Context, prompt string, start func(context.Context, Engine) (*Stream, error)) (*Stream, error) {
+	ctx, gen := c.generations.start(ctx, prompt)
+
+	candidates := c.candidates()
+	var errs []error
+
+	current, rest, errs := c.startNext(ctx, candidates, start, errs)
+	if current == nil {
+		c.generations.finish(gen)
+		return nil, allFailed(errs)
+	}
+
+	stream, tokenChan := gen.stream()
+
+	go func() {
+		defer c.generations.finish(gen)
+
+		for {
+			emitted, end := c.relay(ctx, current, gen, tokenChan)
+			err := end.Err
+			if err == nil {
+				stream.Finish(end)
+				return
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", current.backend.name, err))
+			if emitted || ctx.Err() != nil {
+				stream.Finish(StreamEnd{Err: err})
+				return
+			}
+
+			current, rest, errs = c.startNext(ctx, rest, start, errs)
+			if current == nil {
+				stream.Finish(StreamEnd{Err: allFailed(errs)})
+				return
+			}
+		}
+	}()
+
+	return stream, nil
+}
+
+// startNext starts the generation on the first of the candidates that
+// accepts it, and returns the candidates left.
+func (c *Composite) startNext(ctx context.Context, candidates []*backend, start func(context.Context, Engine) (*Stream, error), errs []error) (*attempt, []*backend, []error) {
+	for len(candidates) > 0 && ctx.Err() == nil {
+		b := candidates[0]
+		candidates = candidates[1:]
+
+		b.begin()
+		attemptCtx, cancel := context.WithCancel(ctx)
+		stream, err := start(attemptCtx, b.engine)
+		if err != nil {
+			cancel()
+			c.end(ctx, b, err)
+			errs = append(errs, fmt.Errorf("%s: %w", b.name, err))
+			continue
+		}
+		return &attempt{backend: b, stream: stream, cancel: cancel}, candidates, errs
+	}
+	if err := ctx.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return nil, nil, errs
+}
+
+// relay forwards the tokens of an attempt and reports whether any were
+// forwarded, and how the attempt ended.
+func (c *Composite) relay(ctx context.Context, a *attempt, gen *generation, tokenChan chan<- string) (emitted bool, end StreamEnd) {
+	defer func() {
+		a.cancel()
+		c.end(ctx, a.backend, end.Err)
+	}()
+
+	var timeout <-chan time.Time
+	if c.firstTokenTimeout > 0 {
+		timer := time.NewTimer(c.firstTokenTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		select {
+		case token, ok := <-a.stream.Tokens:
+			if !ok {
+				if err := a.stream.Err(); err != nil {
+					return emitted, StreamEnd{Err: err}
+				}
+				if !emitted {
+					return false, StreamEnd{Err: ErrEmptyReply}
+				}
+				return true, StreamEnd{Reason: a.stream.FinishReason(), Usage: a.stream.Usage()}
+			}
+			if !emitted {
+				emitted, timeout = true, nil
+			}
+			select {
+			case tokenChan <- token:
+				gen.token()
+			case <-ctx.Done():
+				go a.stream.Drain()
+				return true, StreamEnd{Err: ctx.Err()}
+			}
+		case <-timeout:
+			a.cancel()
+			go a.stream.Drain()
+			return false, StreamEnd{Err: ErrFirstTokenTimeout}
+		case <-ctx.Done():
+			go a.stream.Drain()
+			return emitted, StreamEnd{Err: ctx.Err()}
+		}
+	}
+}
+
+func allFailed(errs []error) error {
+	return fmt.Errorf("%w: %w", ErrAllEnginesFailed, errors.Join(errs...))
+}
+
+// candidates orders the engines according to the strategy, putting the
+// unavailable ones last.
+func (c *Composite) candidates() []*backend {
+	c.mu.Lock()
+	ordered := make([]*backend, len(c.backends))
+	copy(ordered, c.backends)
+	if c.strategy == RoundRobin && len(ordered) > 0 {
+		first := c.next % len(ordered)
+		ordered = append(ordered[first:], ordered[:first]...)
+		c.next++
+	}
+	c.mu.Unlock()
+
+	now := time.Now()
+	available := make(map[*backend]bool, len(ordered))
+	load := make(map[*backend]int, len(ordered))
+	for _, b := range ordered {
+		b.mu.Lock()
+		available[b] = b.healthy && !now.Before(b.downUntil)
+		load[b] = b.stats.Active
+		b.mu.Unlock()
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		a, b := ordered[i], ordered[j]
+		if available[a] != available[b] {
+			return available[a]
+		}
+		return c.strategy == LeastLoaded && load[a] < load[b]
+	})
+	return ordered
+}
+
+// end records the outcome of an attempt. Cancellation by the caller does not
+// count against the engine.
+func (c *Composite) end(ctx context.Context, b *backend, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.stats.Active--
+	switch {
+	case err == nil:
+		b.stats.ConsecutiveFailures = 0
+	case ctx.Err() != nil:
+	default:
+		b.stats.Failures++
+		if errors.Is(err, ErrFirstTokenTimeout) {
+			b.stats.Timeouts++
+		}
+		b.stats.ConsecutiveFailures++
+		b.stats.LastError = err.Error()
+		b.stats.LastFailure = time.Now()
+		if c.failureThreshold > 0 && b.stats.ConsecutiveFailures >= c.failureThreshold {
+			b.downUntil = time.Now().Add(c.cooldown)
+		}
+	}
+}
+
+// StopGeneration stops a generation in progress on whichever engine runs it,
+// by the ID of the stream returned by the Composite.
+func (c *Composite) StopGeneration(ctx context.Context, id GenerationID) error {
+	return c.generations.stop(id)
+}
+
+// ActiveGenerations describes the generations in progress, oldest first. The
+// IDs are those of the Composite, not of the engine running each generation.
+func (c *Composite) ActiveGenerations() []Generation {
+	return c.generations.list()
+}
+
+// StopAll stops every generation in progress and returns how many were
+// stopped.
+func (c *Composite) StopAll() int {
+	return c.generations.stopAll()
+}
+
+// Stats returns the statistics of each engine, in the order given.
+func (c *Composite) Stats() []EngineStats {
+	now := time.Now()
+	stats := make([]EngineStats, len(c.backends))
+	for i, b := range c.backends {
+		b.mu.Lock()
+		stats[i] = b.stats
+		stats[i].Name = b.name
+		stats[i].Healthy = b.healthy && !now.Before(b.downUntil)
+		b.mu.Unlock()
+	}
+	return stats
+}
+
+// CheckHealth runs the health check of every engine that has one.
+func (c *Composite) CheckHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, b := range c.backends {
+		checker, ok := b.engine.(HealthChecker)
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(b *backend) {
+			defer wg.Done()
+			err := checker.Health(ctx)
+
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.healthy = err == nil
+			if err != nil {
+				b.stats.LastError = err.Error()
+			}
+		}(b)
+	}
+	wg.Wait()
+}
+
+// StartHealthChecks checks the health of the engines every interval until
+// Close is called.
+func (c *Composite) StartHealthChecks(interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c.mu.Lock()
+	if c.stopChecks != nil {
+		c.stopChecks()
+	}
+	c.stopChecks = cancel
+	c.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			checkCtx, cancelCheck := context.WithTimeout(ctx, interval)
+			c.CheckHealth(checkCtx)
+			cancelCheck()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Close stops the health checks.
+func (c *Composite) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stopChecks != nil {
+		c.stopChecks()
+		c.stopChecks = nil
+	}
+	return nil
+}
+
+// backend is the state of one engine of a Composite.
+type backend struct {
+	name   string
+	engine Engine
+
+	mu        sync.Mutex
+	stats     EngineStats
+	healthy   bool      // Result of the last health check
+	downUntil time.Time // End of the cooldown after repeated failures
+}
+
+func (b *backend) begin() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stats.Active++
+	b.stats.Requests++
+}
+
+// checkHealth requests url and expects a 200 response.
+func checkHealth(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check returned status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrUnknownGeneration is returned when stopping a generation that is not in
+// progress.
+var ErrUnknownGeneration = errors.New("generation not found or already completed")
+
+// GenerationID identifies a generation, so that it can be stopped without
+// affecting concurrent generations of the same prompt. IDs are unique within
+// the process; zero means the engine does not track its generations.
+type GenerationID uint64
+
+var lastGenerationID atomic.Uint64
+
+// Generation describes a generation in progress.
+type Generation struct {
+	ID      GenerationID `json:"id"`
+	Prompt  string       `json:"prompt"`
+	Started time.Time    `json:"started"`
+	Tokens  int          `json:"tokens"` // Tokens sent so far
+}
+
+// generations tracks the generations in progress of an engine. The zero value
+// is ready to use.
+type generations struct {
+	mu     sync.Mutex
+	active map[GenerationID]*generation
+}
+
+// generation is a tracked generation and the cancel func of its context.
+type generation struct {
+	id      GenerationID
+	prompt  string
+	started time.Time
+	tokens  atomic.Int64
+	cancel  context.CancelFunc
+}
+
+// start tracks a new generation of prompt and returns the context it runs
+// under, which is canceled when it is stopped.
+func (g *generations) start(ctx context.Context, prompt string) (context.Context, *generation) {
+	ctx, cancel := context.WithCancel(ctx)
+	gen := &generation{
+		id:      GenerationID(lastGenerationID.Add(1)),
+		prompt:  prompt,
+		started: time.Now(),
+		cancel:  cancel,
+	}
+
+	g.mu.Lock()
+	if g.active == nil {
+		g.active = make(map[GenerationID]*generation)
+	}
+	g.active[gen.id] = gen
+	g.mu.Unlock()
+
+	return ctx, gen
+}
+
+// finish stops tracking a generation and releases its context.
+func (g *generations) finish(gen *generation) {
+	gen.cancel()
+	g.mu.Lock()
+	delete(g.active, gen.id)
+	g.mu.Unlock()
+}
+
+// stop cancels the generation with the given ID.
+func (g *generations) stop(id GenerationID) error {
+	g.mu.Lock()
+	gen, exists := g.active[id]
+	delete(g.active, id)
+	g.mu.Unlock()
+
+	if !exists {
+		return fmt.Errorf("%w: %d", ErrUnknownGeneration, id)
+	}
+	gen.cancel()
+	return nil
+}
+
+// stopAll cancels every generation in progress and returns how many there were.
+func (g *generations) stopAll() int {
+	g.mu.Lock()
+	active := g.active
+	g.active = nil
+	g.mu.Unlock()
+
+	for _, gen := range active {
+		gen.cancel()
+	}
+	return len(active)
+}
+
+// list describes the generations in progress, oldest first.
+func (g *generations) list() []Generation {
+	g.mu.Lock()
+	list := make([]Generation, 0, len(g.active))
+	for _, gen := range g.active {
+		list = append(list, Generation{
+			ID:      gen.id,
+			Prompt:  gen.prompt,
+			Started: gen.started,
+			Tokens:  int(gen.tokens.Load()),
+		})
+	}
+	g.mu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
+
+// token counts a token sent to the caller.
+func (gen *generation) token() {
+	gen.tokens.Add(1)
+}
+
+// stream creates the stream of the generation.
+func (gen *generation) stream() (*Stream, chan<- string) {
+	stream, tokenChan := NewStream()
+	stream.ID = gen.id
+	return stream, tokenChan
+}
@@ -0,0 +1,305 @@
+// Package jsonextract finds and repairs JSON in free-form LLM output.
+//
+// Models without a JSON mode often wrap their reply in markdown fences, add
+// prose around it, or produce JavaScript-style objects with single quotes,
+// unquoted keys, comments and trailing commas. Extract locates the first
+// balanced JSON object or array in such output, repairs the common defects,
+// and reports which repairs were needed.
+package jsonextract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrNoJSON      = errors.New("no JSON object or array found")
+	ErrInvalidJSON = errors.New("invalid JSON after repair")
+)
+
+// Repair describes a fix applied to the text.
+type Repair string
+
+const (
+	RepairCodeFence      Repair = "removed markdown code fence"
+	RepairLeadingText    Repair = "skipped text before JSON"
+	RepairTrailingText   Repair = "skipped text after JSON"
+	RepairSingleQuotes   Repair = "converted single-quoted strings"
+	RepairUnquotedKeys   Repair = "quoted object keys"
+	RepairTrailingCommas Repair = "removed trailing commas"
+	RepairComments       Repair = "removed comments"
+	RepairLiterals       Repair = "converted Python literals"
+	RepairUnclosed       Repair = "closed unterminated strings or brackets"
+)
+
+// Result is the extracted JSON and the repairs that were applied to it.
+type Result struct {
+	JSON    string   `json:"json"`
+	Repairs []Repair `json:"repairs,omitempty"`
+}
+
+// Repaired reports whether any repair was needed.
+func (r Result) Repaired() bool {
+	return len(r.Repairs) > 0
+}
+
+// Extract returns the first balanced JSON object or array in text. When the
+// value starting at the first bracket cannot be repaired, e.g. because the
+// prose before the JSON contains brackets, the later brackets are tried in
+// turn; if none works, the error is that of the first.
+func Extract(text string) (Result, error) {
+	fenced := false
+	if inner, ok := stripFence(text); ok {
+		text, fenced = inner, true
+	}
+
+	start := strings.IndexAny(text, "{[")
+	if start < 0 {
+		return Result{}, ErrNoJSON
+	}
+
+	first, err := extractAt(text, start, fenced)
+	for next := start + 1; err != nil && next < len(text); next++ {
+		offset := strings.IndexAny(text[next:], "{[")
+		if offset < 0 {
+			break
+		}
+		next += offset
+		if result, err := extractAt(text, next, fenced); err == nil {
+			return result, nil
+		}
+	}
+	return first, err
+}
+
+// extractAt repairs the value starting at text[start].
+func extractAt(text string, start int, fenced bool) (Result, error) {
+	var repairs repairSet
+	if fenced {
+		repairs.add(RepairCodeFence)
+	}
+	if strings.TrimSpace(text[:start]) != "" {
+		repairs.add(RepairLeadingText)
+	}
+
+	s := scanner{input: text[start:], repairs: &repairs}
+	rest := s.scan()
+	if strings.TrimSpace(rest) != "" {
+		repairs.add(RepairTrailingText)
+	}
+
+	result := Result{JSON: s.out.String(), Repairs: repairs.list}
+	if !json.Valid([]byte(result.JSON)) {
+		return result, fmt.Errorf("%w: %s", ErrInvalidJSON, result.JSON)
+	}
+	return result, nil
+}
+
+// stripFence returns the contents of the first markdown code fence, if any.
+func stripFence(text string) (string, bool) {
+	open := strings.Index(text, "```")
+	if open < 0 {
+		return text, false
+	}
+
+	body := text[open+3:]
+	// Skip the language tag, e.g. ```json.
+	if newline := strings.IndexByte(body, '\n'); newline >= 0 && !strings.ContainsAny(body[:newline], "{[") {
+		body = body[newline+1:]
+	}
+
+	if end := strings.Index(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return body, true
+}
+
+type repairSet struct {
+	list []Repair
+}
+
+func (r *repairSet) add(repair Repair) {
+	for _, existing := range r.list {
+		if existing == repair {
+			return
+		}
+	}
+	r.list = append(r.list, repair)
+}
+
+// scanner copies one JSON value from input to out, repairing it on the way.
+type scanner struct {
+	input   string
+	pos     int
+	out     strings.Builder
+	stack   []byte // Expected closing brackets
+	repairs *repairSet
+}
+
+// scan processes the value and returns the unconsumed remainder of the input.
+func (s *scanner) scan() string {
+	for s.pos < len(s.input) {
+		c := s.input[s.pos]
+
+		switch {
+		case c == '"' || c == '\'':
+			s.scanString(c)
+		case c == '{' || c == '[':
+			s.stack = append(s.stack, closing(c))
+			s.out.WriteByte(c)
+			s.pos++
+		case c == '}' || c == ']':
+			s.dropTrailingComma()
+			if len(s.stack) > 0 {
+				s.out.WriteByte(s.stack[len(s.stack)-1])
+				s.stack = s.stack[:len(s.stack)-1]
+			}
+			s.pos++
+			if len(s.stack) == 0 {
+				return s.input[s.pos:]
+			}
+		case c == '/' && s.pos+1 < len(s.input) && (s.input[s.pos+1] == '/' || s.input[s.pos+1] == '*'):
+			s.skipComment()
+		case isIdentStart(c):
+			s.scanWord()
+		default:
+			s.out.WriteByte(c)
+			s.pos++
+		}
+	}
+
+	// The input ended before the value was complete.
+	if len(s.stack) > 0 {
+		s.repairs.add(RepairUnclosed)
+		s.dropTrailingComma()
+		for i := len(s.stack) - 1; i >= 0; i-- {
+			s.out.WriteByte(s.stack[i])
+		}
+	}
+	return ""
+}
+
+// scanString copies a string literal, converting single quotes to double quotes.
+func (s *scanner) scanString(quote byte) {
+	if quote == '\'' {
+		s.repairs.add(RepairSingleQuotes)
+	}
+
+	s.out.WriteByte('"')
+	s.pos++
+
+	for s.pos < len(s.input) {
+		c := s.input[s.pos]
+		switch {
+		case c == '\\' && s.pos+1 < len(s.input):
+			next := s.input[s.pos+1]
+			if quote == '\'' && next == '\'' {
+				// \' is not a valid JSON escape.
+				s.out.WriteByte('\'')
+			} else {
+				s.out.WriteByte(c)
+				s.out.WriteByte(next)
+			}
+			s.pos += 2
+			continue
+		case c == quote:
+			s.out.WriteByte('"')
+			s.pos++
+			return
+		case c == '"':
+			s.out.WriteString(`\"`)
+		case c == '\n':
+			s.out.WriteString(`\n`)
+		default:
+			s.out.WriteByte(c)
+		}
+		s.pos++
+	}
+
+	s.repairs.add(RepairUnclosed)
+	s.out.WriteByte('"')
+}
+
+// scanWord handles bare identifiers: JSON literals are kept, Python literals
+// are converted, and identifiers used as object keys are quoted.
+func (s *scanner) scanWord() {
+	end := s.pos
+	for end < len(s.input) && isIdentPart(s.input[end]) {
+		end++
+	}
+	word := s.input[s.pos:end]
+	s.pos = end
+
+	switch word {
+	case "true", "false", "null":
+		s.out.WriteString(word)
+		return
+	case "True", "False", "None":
+		s.repairs.add(RepairLiterals)
+		s.out.WriteString(map[string]string{"True": "true", "False": "false", "None": "null"}[word])
+		return
+	}
+
+	if s.followedByColon() {
+		s.repairs.add(RepairUnquotedKeys)
+		s.out.WriteString(`"` + word + `"`)
+		return
+	}
+
+	// Leave anything else for json.Valid to reject.
+	s.out.WriteString(word)
+}
+
+func (s *scanner) followedByColon() bool {
+	rest := strings.TrimLeftFunc(s.input[s.pos:], unicode.IsSpace)
+	return strings.HasPrefix(rest, ":")
+}
+
+func (s *scanner) skipComment() {
+	s.repairs.add(RepairComments)
+	if s.input[s.pos+1] == '/' {
+		end := strings.IndexByte(s.input[s.pos:], '\n')
+		if end < 0 {
+			s.pos = len(s.input)
+			return
+		}
+		s.pos += end
+		return
+	}
+
+	end := strings.Index(s.input[s.pos+2:], "*/")
+	if end < 0 {
+		s.pos = len(s.input)
+		return
+	}
+	s.pos += end + 4
+}
+
+// dropTrailingComma removes a comma emitted just before a closing bracket.
+func (s *scanner) dropTrailingComma() {
+	out := s.out.String()
+	trimmed := strings.TrimRightFunc(out, unicode.IsSpace)
+	if strings.HasSuffix(trimmed, ",") {
+		s.repairs.add(RepairTrailingCommas)
+		s.out.Reset()
+		s.out.WriteString(trimmed[:len(trimmed)-1])
+	}
+}
+
+func closing(open byte) byte {
+	if open == '{' {
+		return '}'
+	}
+	return ']'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,95 @@
+package jsonextract
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		json    string
+		repairs []Repair
+	}{
+		{"valid object", `{"function": "Add", "arguments": [3, 4]}`, `{"function": "Add", "arguments": [3, 4]}`, nil},
+		{"valid array", `[1, 2]`, `[1, 2]`, nil},
+		{"code fence", "```json\n{\"a\": 1}\n```", `{"a": 1}`, []Repair{RepairCodeFence}},
+		{"code fence without tag", "```\n[1]\n```", `[1]`, []Repair{RepairCodeFence}},
+		{"leading text", `Sure: {"a": 1}`, `{"a": 1}`, []Repair{RepairLeadingText}},
+		{"trailing text", `{"a": 1} Hope this helps!`, `{"a": 1}`, []Repair{RepairTrailingText}},
+		{"single quotes", `{'a': 'it\'s "x"'}`, `{"a": "it's \"x\""}`, []Repair{RepairSingleQuotes}},
+		{"unquoted keys", `{function: "Add", _n1: 2}`, `{"function": "Add", "_n1": 2}`, []Repair{RepairUnquotedKeys}},
+		{"trailing commas", `{"a": [1, 2, ], }`, `{"a": [1, 2]}`, []Repair{RepairTrailingCommas}},
+		{"line comment", "{\"a\": 1 // one\n}", "{\"a\": 1 \n}", []Repair{RepairComments}},
+		{"block comment", `{"a": /* one */ 1}`, `{"a":  1}`, []Repair{RepairComments}},
+		{"python literals", `{"a": True, "b": False, "c": None}`, `{"a": true, "b": false, "c": null}`, []Repair{RepairLiterals}},
+		{"unclosed brackets", `{"a": [1, 2`, `{"a": [1, 2]}`, []Repair{RepairUnclosed}},
+		{"unclosed string", `{"a": "b`, `{"a": "b"}`, []Repair{RepairUnclosed}},
+		{"newline in string", "{\"a\": \"b\nc\"}", `{"a": "b\nc"}`, nil},
+		{"brackets in strings", `{"a": "}]"}`, `{"a": "}]"}`, nil},
+		{
+			"several repairs",
+			"Here you go:\n```js\n{function: 'Add', arguments: [3, 4,],}\n```",
+			`{"function": "Add", "arguments": [3, 4]}`,
+			[]Repair{RepairCodeFence, RepairUnquotedKeys, RepairSingleQuotes, RepairTrailingCommas},
+		},
+		{
+			"later bracket",
+			`Use [the tool] like this: {"function": "Add"}`,
+			`{"function": "Add"}`,
+			[]Repair{RepairLeadingText},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Extract(tt.text)
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			if result.JSON != tt.json {
+				t.Errorf("JSON = %s, want %s", result.JSON, tt.json)
+			}
+			if !slices.Equal(result.Repairs, tt.repairs) {
+				t.Errorf("Repairs = %q, want %q", result.Repairs, tt.repairs)
+			}
+			if result.Repaired() != (len(tt.repairs) > 0) {
+				t.Errorf("Repaired = %v with repairs %q", result.Repaired(), result.Repairs)
+			}
+		})
+	}
+}
+
+func TestExtractErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want error
+	}{
+		{"empty", "", ErrNoJSON},
+		{"prose", "I cannot help with that.", ErrNoJSON},
+		{"empty fence", "```\n```", ErrNoJSON},
+		{"unknown identifier", `{"a": undefined}`, ErrInvalidJSON},
+		{"no valid bracket", `[not json] {nor this}`, ErrInvalidJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Extract(tt.text); !errors.Is(err, tt.want) {
+				t.Errorf("Extract error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractReportsFirstError(t *testing.T) {
+	_, err := Extract(`[oops] {nor: this}`)
+	if !errors.Is(err, ErrInvalidJSON) {
+		t.Fatalf("Extract error = %v, want %v", err, ErrInvalidJSON)
+	}
+	if want := ErrInvalidJSON.Error() + ": [oops]"; err.Error() != want {
+		t.Errorf("Extract error = %q, want %q", err, want)
+	}
+}
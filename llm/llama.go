@@ -3,8 +3,9 @@ package llm
 import (
 	"context"
 	"fmt"
-	"log"
-	"sync"
+	"net/http"
+	"os"
+	"strings"
 
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/llms/ollama"
@@ -13,72 +14,139 @@ import (
 // OllamaEngine implements the LLMEngineType interface using the Ollama model.
 type OllamaEngine struct {
 	model       string
-	mu          sync.Mutex
-	activeTasks map[string]context.CancelFunc
+	format      string
+	serverURL   string
+	generations generations
 	client      *ollama.LLM
+	httpClient  *http.Client
 }
 
-func NewOllamaEngine(model string) (*OllamaEngine, error) {
+// Option configures an OllamaEngine.
+type Option func(*options)
 
-	llm, err := ollama.New(ollama.WithModel(model), ollama.WithFormat("json"))
+type options struct {
+	format    string
+	serverURL string
+}
+
+// WithFormat sets the output format requested from Ollama. The default is
+// "json"; an empty format lets the model reply in free text, as needed for
+// natural-language answers.
+func WithFormat(format string) Option {
+	return func(o *options) {
+		o.format = format
+	}
+}
+
+// WithServerURL sets the address of the Ollama server. The default is taken
+// from OLLAMA_HOST, falling back to http://127.0.0.1:11434.
+func WithServerURL(serverURL string) Option {
+	return func(o *options) {
+		o.serverURL = serverURL
+	}
+}
+
+func NewOllamaEngine(model string, opts ...Option) (*OllamaEngine, error) {
+	o := options{format: "json", serverURL: defaultOllamaURL()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ollamaOpts := []ollama.Option{ollama.WithModel(model), ollama.WithServerURL(o.serverURL)}
+	if o.format != "" {
+		ollamaOpts = append(ollamaOpts, ollama.WithFormat(o.format))
+	}
+
+	llm, err := ollama.New(ollamaOpts...)
 	if err != nil {
 		return nil, err
 	}
 	return &OllamaEngine{
-		model:       model,
-		activeTasks: make(map[string]context.CancelFunc),
-		client:      llm,
+		model:      model,
+		format:     o.format,
+		serverURL:  strings.TrimSuffix(o.serverURL, "/"),
+		client:     llm,
+		httpClient: http.DefaultClient,
 	}, nil
 }
 
-func (o *OllamaEngine) GenerateTokens(ctx context.Context, prompt string) (<-chan string, error) {
-	o.mu.Lock()
-	ctx, cancel := context.WithCancel(ctx)
-	o.activeTasks[prompt] = cancel
-	o.mu.Unlock()
+func defaultOllamaURL() string {
+	host := os.Getenv("OLLAMA_HOST")
+	if host == "" {
+		return "http://127.0.0.1:11434"
+	}
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+	return host
+}
+
+// Fingerprint describes the model and options that determine the output.
+func (o *OllamaEngine) Fingerprint() string {
+	return fmt.Sprintf("ollama model=%s format=%q temperature=0", o.model, o.format)
+}
+
+// Health checks that the Ollama server responds.
+func (o *OllamaEngine) Health(ctx context.Context) error {
+	return checkHealth(ctx, o.httpClient, o.serverURL+"/api/version")
+}
 
-	tokenChan := make(chan string, 100)
+// GenerateTokens streams the reply to the prompt. A failure of the
+// generation is reported by the stream's Err.
+func (o *OllamaEngine) GenerateTokens(ctx context.Context, prompt string) (*Stream, error) {
+	ctx, gen := o.generations.start(ctx, prompt)
+	stream, tokenChan := gen.stream()
 
 	go func() {
-		defer close(tokenChan)
-		defer func() {
-			o.mu.Lock()
-			delete(o.activeTasks, prompt)
-			o.mu.Unlock()
-		}()
-
-		_, err := o.client.Call(ctx, prompt,
+		defer o.generations.finish(gen)
+
+		response, err := o.client.GenerateContent(ctx,
+			[]llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, prompt)},
 			llms.WithTemperature(0),
 			llms.WithStreamingFunc(func(ctx context.Context, chunk []byte) error {
 				select {
 				case <-ctx.Done():
-					log.Println("Context canceled, stopping token generation")
 					return ctx.Err()
 				case tokenChan <- string(chunk):
+					gen.token()
 				}
 				return nil
 			}),
 		)
 
-		if err != nil && ctx.Err() == nil {
-			log.Printf("Error generating tokens: %v", err)
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		end := StreamEnd{Err: err}
+		if err == nil && len(response.Choices) > 0 {
+			info := response.Choices[0].GenerationInfo
+			end.Usage = Usage{PromptTokens: intOf(info["PromptTokens"]), CompletionTokens: intOf(info["CompletionTokens"])}
 		}
+		stream.Finish(end)
 	}()
 
-	return tokenChan, nil
+	return stream, nil
 }
 
-func (o *OllamaEngine) StopGeneration(ctx context.Context, prompt string) error {
-	o.mu.Lock()
-	defer o.mu.Unlock()
+// intOf reads a token count from langchaingo's generation info.
+func intOf(v any) int {
+	n, _ := v.(int)
+	return n
+}
 
-	cancel, exists := o.activeTasks[prompt]
-	if !exists {
-		return fmt.Errorf("prompt %q not found or already completed", prompt)
-	}
+// StopGeneration stops the generation with the given ID, as found in its
+// stream.
+func (o *OllamaEngine) StopGeneration(ctx context.Context, id GenerationID) error {
+	return o.generations.stop(id)
+}
 
-	cancel()
-	delete(o.activeTasks, prompt)
+// ActiveGenerations describes the generations in progress, oldest first.
+func (o *OllamaEngine) ActiveGenerations() []Generation {
+	return o.generations.list()
+}
 
-	return nil
+// StopAll stops every generation in progress, e.g. on shutdown, and returns
+// how many were stopped.
+func (o *OllamaEngine) StopAll() int {
+	return o.generations.stopAll()
 }
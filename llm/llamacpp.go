@@ -0,0 +1,169 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-agent/tools/grammar"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// LlamaCppEngine generates tokens with a llama.cpp server (llama-server),
+// which supports GBNF grammars for constrained sampling.
+type LlamaCppEngine struct {
+	serverURL   string
+	maxTokens   int
+	httpClient  *http.Client
+	generations generations
+}
+
+// NewLlamaCppEngine creates an engine for the llama.cpp server at serverURL,
+// e.g. "http://127.0.0.1:8080".
+func NewLlamaCppEngine(serverURL string) *LlamaCppEngine {
+	return &LlamaCppEngine{
+		serverURL:  strings.TrimSuffix(serverURL, "/"),
+		maxTokens:  512,
+		httpClient: http.DefaultClient,
+	}
+}
+
+// completionRequest is the body of the llama.cpp /completion endpoint.
+type completionRequest struct {
+	Prompt      string  `json:"prompt"`
+	Stream      bool    `json:"stream"`
+	Temperature float64 `json:"temperature"`
+	NPredict    int     `json:"n_predict"`
+	Grammar     string  `json:"grammar,omitempty"`
+}
+
+// completionChunk is one server-sent event of a streamed completion.
+type completionChunk struct {
+	Content         string `json:"content"`
+	Stop            bool   `json:"stop"`
+	StoppedLimit    bool   `json:"stopped_limit,omitempty"`    // The reply reached n_predict, in the last event
+	TokensEvaluated int    `json:"tokens_evaluated,omitempty"` // Prompt tokens, in the last event
+	TokensPredicted int    `json:"tokens_predicted,omitempty"` // Generated tokens, in the last event
+}
+
+// Fingerprint describes the server and options that determine the output. The
+// model is whichever the server has loaded, so it is identified by the server.
+func (l *LlamaCppEngine) Fingerprint() string {
+	return fmt.Sprintf("llama.cpp server=%s n_predict=%d temperature=0", l.serverURL, l.maxTokens)
+}
+
+// Health checks that the llama.cpp server is up and has loaded its model.
+func (l *LlamaCppEngine) Health(ctx context.Context) error {
+	return checkHealth(ctx, l.httpClient, l.serverURL+"/health")
+}
+
+// GenerateTokens streams the reply to the prompt. A failure of the
+// generation is reported by the stream's Err.
+func (l *LlamaCppEngine) GenerateTokens(ctx context.Context, prompt string) (*Stream, error) {
+	return l.complete(ctx, completionRequest{Prompt: prompt})
+}
+
+// GenerateConstrained generates tokens restricted to the grammar's GBNF rules.
+func (l *LlamaCppEngine) GenerateConstrained(ctx context.Context, prompt string, g grammar.Grammar) (*Stream, error) {
+	return l.complete(ctx, completionRequest{Prompt: prompt, Grammar: g.GBNF})
+}
+
+func (l *LlamaCppEngine) complete(ctx context.Context, request completionRequest) (*Stream, error) {
+	request.Stream = true
+	request.NPredict = l.maxTokens
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, gen := l.generations.start(ctx, request.Prompt)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.serverURL+"/completion", bytes.NewReader(body))
+	if err != nil {
+		l.generations.finish(gen)
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		l.generations.finish(gen)
+		return nil, fmt.Errorf("error calling llama.cpp server: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		l.generations.finish(gen)
+		return nil, fmt.Errorf("llama.cpp server returned status %s", resp.Status)
+	}
+
+	stream, tokenChan := gen.stream()
+
+	go func() {
+		defer l.generations.finish(gen)
+		defer resp.Body.Close()
+		stream.Finish(readCompletionStream(ctx, resp.Body, tokenChan, gen))
+	}()
+
+	return stream, nil
+}
+
+// StopGeneration stops the generation with the given ID, as found in its
+// stream.
+func (l *LlamaCppEngine) StopGeneration(ctx context.Context, id GenerationID) error {
+	return l.generations.stop(id)
+}
+
+// ActiveGenerations describes the generations in progress, oldest first.
+func (l *LlamaCppEngine) ActiveGenerations() []Generation {
+	return l.generations.list()
+}
+
+// StopAll stops every generation in progress, e.g. on shutdown, and returns
+// how many were stopped.
+func (l *LlamaCppEngine) StopAll() int {
+	return l.generations.stopAll()
+}
+
+// readCompletionStream forwards the tokens of a streamed /completion response
+// and reports how it ended.
+func readCompletionStream(ctx context.Context, body io.Reader, tokenChan chan<- string, gen *generation) StreamEnd {
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+
+		var chunk completionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return StreamEnd{Err: fmt.Errorf("error decoding llama.cpp response: %w", err)}
+		}
+
+		select {
+		case <-ctx.Done():
+			return StreamEnd{Err: ctx.Err()}
+		case tokenChan <- chunk.Content:
+			gen.token()
+		}
+
+		if chunk.Stop {
+			end := StreamEnd{Usage: Usage{PromptTokens: chunk.TokensEvaluated, CompletionTokens: chunk.TokensPredicted}}
+			if chunk.StoppedLimit {
+				end.Reason = FinishLength
+			}
+			return end
+		}
+	}
+
+	if ctx.Err() != nil {
+		return StreamEnd{Err: ctx.Err()}
+	}
+	if err := scanner.Err(); err != nil {
+		return StreamEnd{Err: fmt.Errorf("error reading llama.cpp response: %w", err)}
+	}
+	return StreamEnd{Err: fmt.Errorf("llama.cpp response ended early: %w", io.ErrUnexpectedEOF)}
+}
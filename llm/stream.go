@@ -0,0 +1,100 @@
+package llm
+
+import (
+	"context"
+	"errors"
+)
+
+// FinishReason tells why a generation ended.
+type FinishReason string
+
+const (
+	FinishStop     FinishReason = "stop"     // The model completed its reply
+	FinishLength   FinishReason = "length"   // The reply reached the token limit
+	FinishCanceled FinishReason = "canceled" // The generation was stopped or its context canceled
+	FinishError    FinishReason = "error"    // The engine failed; see Err
+)
+
+// Usage counts the tokens of a generation, as reported by the engine. The
+// counts are zero when the engine does not report them.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// Stream is the output of a generation. Tokens are sent on Tokens, which is
+// closed when the generation ends; Err, FinishReason and Usage then report
+// how it ended. Without them a failed generation could not be told apart from
+// an empty reply.
+type Stream struct {
+	Tokens <-chan string
+	ID     GenerationID // Handle to stop the generation with; zero when the engine does not track it
+
+	done   chan struct{}
+	end    StreamEnd
+	finish func(StreamEnd)
+}
+
+// StreamEnd describes how a generation ended.
+type StreamEnd struct {
+	Err    error
+	Reason FinishReason // Derived from Err when empty
+	Usage  Usage
+}
+
+// NewStream returns a stream and the channel its producer sends the tokens
+// on. The producer must call Finish exactly once, after the last token; it
+// closes the channel.
+func NewStream() (*Stream, chan<- string) {
+	tokens := make(chan string, 100)
+	s := &Stream{Tokens: tokens, done: make(chan struct{})}
+	s.finish = func(end StreamEnd) {
+		if end.Reason == "" {
+			switch {
+			case end.Err == nil:
+				end.Reason = FinishStop
+			case errors.Is(end.Err, context.Canceled), errors.Is(end.Err, context.DeadlineExceeded):
+				end.Reason = FinishCanceled
+			default:
+				end.Reason = FinishError
+			}
+		}
+		s.end = end
+		close(tokens)
+		close(s.done)
+	}
+	return s, tokens
+}
+
+// Finish ends the stream. It must be called by the producer only.
+func (s *Stream) Finish(end StreamEnd) {
+	s.finish(end)
+}
+
+// Err waits for the generation to end and returns the error that ended it,
+// or nil if it completed. The tokens must be drained, or the generation
+// stopped, for it to return.
+func (s *Stream) Err() error {
+	<-s.done
+	return s.end.Err
+}
+
+// FinishReason waits for the generation to end and tells why it ended.
+func (s *Stream) FinishReason() FinishReason {
+	<-s.done
+	return s.end.Reason
+}
+
+// Usage waits for the generation to end and returns its token counts.
+func (s *Stream) Usage() Usage {
+	<-s.done
+	return s.end.Usage
+}
+
+// Drain discards the remaining tokens and returns the error that ended the
+// generation.
+func (s *Stream) Drain() error {
+	for range s.Tokens {
+	}
+	return s.Err()
+}
@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-agent/tools/grammar"
+	"io"
+	"net/http"
+)
+
+// generateRequest is the body of Ollama's /api/generate endpoint.
+type generateRequest struct {
+	Model   string          `json:"model"`
+	Prompt  string          `json:"prompt"`
+	Format  json.RawMessage `json:"format,omitempty"`
+	Stream  bool            `json:"stream"`
+	Options map[string]any  `json:"options,omitempty"`
+}
+
+// generateChunk is one line of the streamed /api/generate response.
+type generateChunk struct {
+	Response        string `json:"response"`
+	Done            bool   `json:"done"`
+	DoneReason      string `json:"done_reason,omitempty"`       // "stop" or "length", in the last chunk
+	PromptEvalCount int    `json:"prompt_eval_count,omitempty"` // Prompt tokens, in the last chunk
+	EvalCount       int    `json:"eval_count,omitempty"`        // Generated tokens, in the last chunk
+	Error           string `json:"error,omitempty"`
+}
+
+// GenerateConstrained generates tokens restricted to the grammar's JSON
+// schema using Ollama structured outputs. langchaingo only passes the format
+// as a string, so the request is made against the Ollama API directly.
+func (o *OllamaEngine) GenerateConstrained(ctx context.Context, prompt string, g grammar.Grammar) (*Stream, error) {
+	schema, err := g.SchemaJSON()
+	if err != nil {
+		return nil, fmt.Errorf("error encoding schema: %w", err)
+	}
+
+	body, err := json.Marshal(generateRequest{
+		Model:   o.model,
+		Prompt:  prompt,
+		Format:  schema,
+		Stream:  true,
+		Options: map[string]any{"temperature": 0},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, gen := o.generations.start(ctx, prompt)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.serverURL+"/api/generate", bytes.NewReader(body))
+	if err != nil {
+		o.generations.finish(gen)
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		o.generations.finish(gen)
+		return nil, fmt.Errorf("error calling ollama: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		o.generations.finish(gen)
+		return nil, fmt.Errorf("ollama returned status %s", resp.Status)
+	}
+
+	stream, tokenChan := gen.stream()
+
+	go func() {
+		defer o.generations.finish(gen)
+		defer resp.Body.Close()
+		stream.Finish(readOllamaStream(ctx, resp.Body, tokenChan, gen))
+	}()
+
+	return stream, nil
+}
+
+// readOllamaStream forwards the tokens of a streamed /api/generate response
+// and reports how it ended.
+func readOllamaStream(ctx context.Context, body io.Reader, tokenChan chan<- string, gen *generation) StreamEnd {
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		var chunk generateChunk
+		if err := json.Unmarshal(scanner.Bytes(), &chunk); err != nil {
+			return StreamEnd{Err: fmt.Errorf("error decoding ollama response: %w", err)}
+		}
+		if chunk.Error != "" {
+			return StreamEnd{Err: fmt.Errorf("ollama: %s", chunk.Error)}
+		}
+
+		select {
+		case <-ctx.Done():
+			return StreamEnd{Err: ctx.Err()}
+		case tokenChan <- chunk.Response:
+			gen.token()
+		}
+
+		if chunk.Done {
+			return StreamEnd{
+				Reason: FinishReason(chunk.DoneReason),
+				Usage:  Usage{PromptTokens: chunk.PromptEvalCount, CompletionTokens: chunk.EvalCount},
+			}
+		}
+	}
+
+	if ctx.Err() != nil {
+		return StreamEnd{Err: ctx.Err()}
+	}
+	if err := scanner.Err(); err != nil {
+		return StreamEnd{Err: fmt.Errorf("error reading ollama response: %w", err)}
+	}
+	return StreamEnd{Err: fmt.Errorf("ollama response ended early: %w", io.ErrUnexpectedEOF)}
+}
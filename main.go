@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"go-agent/agent"
+	"go-agent/approval"
 	"go-agent/calculator"
 	"go-agent/llm"
+	"go-agent/recording"
+	"go-agent/telemetry"
 	"go-agent/tools/toolstore"
+	"os"
 )
 
 func main() {
@@ -21,6 +26,18 @@ func main() {
 		"What is the factorial of 5?",
 		"What is 2 raised to the power of 8?",
 		"What is the sine of 90 degrees?",
+		"What is the capital of France?",
+	}
+
+	// Export traces when an OTLP endpoint is configured
+	ctx := context.Background()
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" {
+		shutdown, err := telemetry.SetupOTLP(ctx, "go-agent")
+		if err != nil {
+			fmt.Printf("Error setting up tracing: %v\n", err)
+			return
+		}
+		defer shutdown(ctx)
 	}
 
 	// Initialize the LLM engine
@@ -30,6 +47,26 @@ func main() {
 		return
 	}
 
+	// A second engine without JSON mode writes the natural-language answers
+	answerEngine, err := llm.NewOllamaEngine("llama3.1:8b", llm.WithFormat(""))
+	if err != nil {
+		fmt.Printf("Error initializing LLM engine: %v\n", err)
+		return
+	}
+
+	// Replay identical prompts from disk instead of querying Ollama again
+	var engine, answer agent.LLMEngine = ollamaEngine, answerEngine
+	if dir := os.Getenv("AGENT_LLM_CACHE"); dir != "" {
+		if engine, err = llm.NewCachingEngine(ollamaEngine, dir); err != nil {
+			fmt.Printf("Error initializing LLM cache: %v\n", err)
+			return
+		}
+		if answer, err = llm.NewCachingEngine(answerEngine, dir); err != nil {
+			fmt.Printf("Error initializing LLM cache: %v\n", err)
+			return
+		}
+	}
+
 	// Get public functions from the calculator package
 	// Create a function store for the tools
 	toolStore, err := toolstore.NewFunctionStoreFromPkg("go-agent/calculator", calculator.FunctionRegistry(), nil)
@@ -39,24 +76,39 @@ func main() {
 	}
 
 	// Initialize the agent
-	goDeveloper := agent.NewAgent(ollamaEngine, toolStore)
+	goDeveloper := agent.NewAgent(engine, toolStore)
+	goDeveloper.SynthesizeAnswer = true
+	goDeveloper.AnswerEngine = answer
+	goDeveloper.Subscribe(agent.NewConsolePrinter(os.Stdout))
+	goDeveloper.Approver = approval.NewCLI(os.Stdin, os.Stdout)
+
+	// Persist a trace of every run for debugging and replay
+	if dir := os.Getenv("AGENT_TRACE_DIR"); dir != "" {
+		goDeveloper.Subscribe(recording.NewRecorder(recording.SaveToDir(dir, nil)))
+	}
 
 	// Evaluate each user request
 	for _, request := range userRequests {
 		fmt.Printf("User Request: %s\n", request)
 
 		// Execute the request using the agent
-		response, err := goDeveloper.Execute(request)
+		response, err := goDeveloper.Respond(ctx, request)
 		if err != nil {
 			fmt.Printf("Error: %+v\n", err)
 			fmt.Println("-----------------------------")
-		} else {
-
-			// Print the response
-			fmt.Printf("Response: %+v\n", response)
-			fmt.Println("-----------------------------")
+			continue
+		}
 
+		// Print the answer alongside the structured result
+		fmt.Printf("Answer: %s\n", response.Answer)
+		if response.Call.Declined() {
+			fmt.Println("No tool applies to the request")
+		} else if response.Err != nil {
+			fmt.Printf("Tool Error: %v\n", response.Err)
+		} else {
+			fmt.Printf("Result: %s\n", response.Result)
 		}
+		fmt.Println("-----------------------------")
 
 	}
 }
@@ -0,0 +1,272 @@
+package metadata
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"sort"
+	"strings"
+)
+
+// Severity classifies a diagnostic.
+type Severity string
+
+const (
+	// SeverityError marks unknown or malformed tags; strict mode fails on these.
+	SeverityError Severity = "error"
+	// SeverityWarning marks documentation that parses but disagrees with the code.
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic describes a problem found while parsing a doc comment.
+type Diagnostic struct {
+	Pos      token.Position `json:"pos"`
+	Severity Severity       `json:"severity"`
+	Tag      string         `json:"tag,omitempty"`
+	Message  string         `json:"message"`
+}
+
+// String formats the diagnostic in the usual "file:line:col: severity: message" form.
+func (d Diagnostic) String() string {
+	if d.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s: %s", d.Pos, d.Severity, d.Message)
+	}
+	return fmt.Sprintf("%s: %s", d.Severity, d.Message)
+}
+
+// tagSubject describes whether a tag takes a subject before the colon.
+type tagSubject int
+
+const (
+	subjectNone     tagSubject = iota // "@since: v1.2"
+	subjectOptional                   // "@example ["phrase"]: Add(1, 2)"
+	subjectName                       // "@param name: desc"
+	subjectRequired                   // "@constraint b != 0: desc"
+	subjectFlag                       // "@pure", without colon or text
+)
+
+// knownTags lists every supported tag and the form of its subject.
+var knownTags = map[string]tagSubject{
+	"param":      subjectName,
+	"return":     subjectRequired,
+	"constraint": subjectRequired,
+	"example":    subjectOptional,
+	"default":    subjectName,
+	"enum":       subjectName,
+	"unit":       subjectName,
+	"deprecated": subjectNone,
+	"since":      subjectNone,
+	"errors":     subjectNone,
+	"see":        subjectNone,
+	"sideeffect": subjectNone,
+	"dangerous":  subjectNone,
+	"pure":       subjectFlag,
+}
+
+// tagUsage shows the expected form of each tag in diagnostics.
+var tagUsage = map[string]string{
+	"param":      "@param name: description",
+	"return":     "@return [name] type: description",
+	"constraint": "@constraint condition: description",
+	"example":    "@example [\"phrase\"]: Call(args) // returns value",
+	"default":    "@default name: value",
+	"enum":       "@enum name: value1, value2",
+	"unit":       "@unit name: unit",
+	"deprecated": "@deprecated: notice",
+	"since":      "@since: version",
+	"errors":     "@errors: description",
+	"see":        "@see: Name1, Name2",
+	"sideeffect": "@sideeffect: what the function changes",
+	"dangerous":  "@dangerous: why the function is dangerous",
+	"pure":       "@pure",
+}
+
+type diagnostics []Diagnostic
+
+func (d *diagnostics) errorf(tag docTag, format string, args ...any) {
+	d.add(SeverityError, tag, format, args...)
+}
+
+func (d *diagnostics) warnf(tag docTag, format string, args ...any) {
+	d.add(SeverityWarning, tag, format, args...)
+}
+
+func (d *diagnostics) add(severity Severity, tag docTag, format string, args ...any) {
+	*d = append(*d, Diagnostic{
+		Pos:      tag.Pos,
+		Severity: severity,
+		Tag:      tag.Name,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// checkTags reports unknown tags and tags that do not follow the grammar.
+// Offending tags are marked as malformed so that they are not interpreted.
+func checkTags(diags *diagnostics, tags []docTag) {
+	for i := range tags {
+		tag := tags[i]
+		before := len(*diags)
+
+		form, known := knownTags[tag.Name]
+		if !known {
+			if suggestion := closestTag(tag.Name); suggestion != "" {
+				diags.errorf(tag, "unknown tag @%s (did you mean @%s?)", tag.Name, suggestion)
+			} else {
+				diags.errorf(tag, "unknown tag @%s", tag.Name)
+			}
+			tags[i].Malformed = true
+			continue
+		}
+
+		usage := tagUsage[tag.Name]
+
+		switch {
+		case tag.Malformed:
+			diags.errorf(tag, "malformed @%s: expected %q", tag.Name, usage)
+		case form == subjectFlag:
+			if tag.Subject != "" || strings.TrimSpace(tag.Text) != "" {
+				diags.errorf(tag, "malformed @%s: unexpected text, expected %q", tag.Name, usage)
+			}
+		case form == subjectNone && tag.Subject != "":
+			diags.errorf(tag, "malformed @%s: unexpected %q before the colon, expected %q", tag.Name, tag.Subject, usage)
+		case (form == subjectName || form == subjectRequired) && tag.Subject == "":
+			diags.errorf(tag, "malformed @%s: missing subject, expected %q", tag.Name, usage)
+		case form == subjectName && !token.IsIdentifier(tag.Subject):
+			diags.errorf(tag, "malformed @%s: %q is not a valid name, expected %q", tag.Name, tag.Subject, usage)
+		case strings.TrimSpace(tag.Text) == "":
+			diags.errorf(tag, "malformed @%s: missing text after the colon", tag.Name)
+		}
+
+		if len(*diags) > before {
+			tags[i].Malformed = true
+		}
+	}
+}
+
+// checkSignature compares the documented parameters and results with the
+// function signature. Problems are reported at the offending tag, or at the
+// declaration for parameters that are not documented.
+func checkSignature(diags *diagnostics, meta FunctionMetaData, entry docEntry, tags []docTag) {
+	funcType := entry.Decl.Type
+	at := docTag{Pos: declPosition(entry)}
+
+	// The tags the documented parameters and results were parsed from, in order.
+	var paramTags, returnTags []docTag
+	for _, tag := range tags {
+		switch {
+		case tag.Malformed:
+		case tag.Name == "param":
+			paramTags = append(paramTags, tag)
+		case tag.Name == "return":
+			returnTags = append(returnTags, tag)
+		}
+	}
+
+	var names []string
+	if funcType.Params != nil {
+		for _, field := range funcType.Params.List {
+			for _, name := range field.Names {
+				names = append(names, name.Name)
+			}
+		}
+	}
+
+	documented := make(map[string]bool)
+	for i, param := range meta.Params {
+		tag := paramTags[i]
+		if documented[param.Name] {
+			diags.warnf(tag, "parameter %q is documented more than once", param.Name)
+		}
+		documented[param.Name] = true
+		if !contains(names, param.Name) {
+			diags.warnf(tag, "@param %q does not match any parameter of %s", param.Name, meta.FunctionName)
+		}
+	}
+	for _, name := range names {
+		if !documented[name] && name != "_" {
+			diags.warnf(at, "parameter %q of %s is not documented", name, meta.FunctionName)
+		}
+	}
+
+	if len(meta.Return) > 0 && len(meta.Return) != countResults(funcType) {
+		// Point at the first surplus @return, or at the last one when some are missing.
+		tag := returnTags[min(countResults(funcType), len(returnTags)-1)]
+		diags.warnf(tag, "%d @return tag(s) documented but %s returns %d non-error value(s)",
+			len(meta.Return), meta.FunctionName, countResults(funcType))
+	}
+}
+
+// declPosition returns the position of the first doc line, or of the
+// declaration itself when it has no documentation.
+func declPosition(entry docEntry) token.Position {
+	if len(entry.Lines) > 0 {
+		return entry.Lines[0].Pos
+	}
+	return token.Position{}
+}
+
+// countResults counts the results of a function type, excluding a trailing error.
+func countResults(funcType *ast.FuncType) int {
+	if funcType.Results == nil {
+		return 0
+	}
+
+	count := 0
+	for i, field := range funcType.Results.List {
+		n := max(len(field.Names), 1)
+		if ident, ok := field.Type.(*ast.Ident); ok && ident.Name == "error" && i == len(funcType.Results.List)-1 {
+			n--
+		}
+		count += n
+	}
+	return count
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// closestTag suggests a known tag within a small edit distance of name.
+func closestTag(name string) string {
+	names := make([]string, 0, len(knownTags))
+	for known := range knownTags {
+		names = append(names, known)
+	}
+	sort.Strings(names)
+
+	best, bestDistance := "", 3
+	for _, known := range names {
+		if d := editDistance(name, known); d < bestDistance {
+			best, bestDistance = known, d
+		}
+	}
+	return best
+}
+
+// editDistance computes the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+
+	return previous[len(b)]
+}
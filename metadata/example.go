@@ -0,0 +1,269 @@
+package metadata
+
+import (
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Example represents a parsed @example annotation.
+//
+// Examples follow the grammar
+//
+//	@example ["user phrase"]: Call(arg1, arg2, ...) [// returns v1, v2 | // error: message]
+//
+// The optional phrase is the natural-language request the call answers and is
+// used to build few-shot demonstrations for the agent.
+type Example struct {
+	Raw           string `json:"raw"`                      // The example text as written
+	Request       string `json:"request,omitempty"`        // Natural-language phrase, if given
+	Function      string `json:"function"`                 // Name of the called function
+	Args          []any  `json:"args"`                     // Evaluated call arguments
+	Expected      []any  `json:"expected,omitempty"`       // Expected return values (excluding errors)
+	ExpectedError string `json:"expected_error,omitempty"` // Expected error message, if the call should fail
+}
+
+// HasExpectation reports whether the example documents an outcome that can be checked.
+func (e Example) HasExpectation() bool {
+	return len(e.Expected) > 0 || e.ExpectedError != ""
+}
+
+// Phrase returns the natural-language request for the example. When no
+// phrase was documented one is synthesised from the call, e.g. "Add 3 and 4".
+func (e Example) Phrase() string {
+	if e.Request != "" {
+		return e.Request
+	}
+
+	args := make([]string, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = fmt.Sprint(arg)
+	}
+
+	switch len(args) {
+	case 0:
+		return e.Function
+	case 1:
+		return fmt.Sprintf("%s %s", e.Function, args[0])
+	default:
+		return fmt.Sprintf("%s %s and %s", e.Function, strings.Join(args[:len(args)-1], ", "), args[len(args)-1])
+	}
+}
+
+// mathConstants are the package-qualified constants allowed in example arguments.
+var mathConstants = map[string]float64{
+	"math.Pi":      math.Pi,
+	"math.E":       math.E,
+	"math.Phi":     math.Phi,
+	"math.Sqrt2":   math.Sqrt2,
+	"math.SqrtE":   math.SqrtE,
+	"math.SqrtPi":  math.SqrtPi,
+	"math.SqrtPhi": math.SqrtPhi,
+	"math.Ln2":     math.Ln2,
+	"math.Log2E":   math.Log2E,
+	"math.Ln10":    math.Ln10,
+	"math.Log10E":  math.Log10E,
+}
+
+// parseExample parses the text and optional phrase of an @example tag.
+func parseExample(phrase, text string) (Example, error) {
+	example := Example{Raw: text}
+
+	if phrase != "" {
+		request, err := strconv.Unquote(phrase)
+		if err != nil {
+			return example, fmt.Errorf("example phrase must be a quoted string, got %s", phrase)
+		}
+		example.Request = request
+	}
+
+	call, comment := splitComment(text)
+
+	function, args, err := parseCall(call)
+	if err != nil {
+		return example, err
+	}
+	example.Function = function
+	example.Args = args
+
+	switch {
+	case comment == "":
+	case strings.HasPrefix(comment, "returns"):
+		expected, err := parseValues(strings.TrimSpace(strings.TrimPrefix(comment, "returns")))
+		if err != nil {
+			return example, fmt.Errorf("invalid expected result %q: %w", comment, err)
+		}
+		example.Expected = expected
+	case strings.HasPrefix(comment, "error"):
+		message := strings.TrimSpace(strings.TrimPrefix(comment, "error"))
+		message = strings.TrimSpace(strings.TrimPrefix(message, ":"))
+		if unquoted, err := strconv.Unquote(message); err == nil {
+			message = unquoted
+		}
+		example.ExpectedError = message
+	}
+
+	return example, nil
+}
+
+// splitComment splits an example into the call and the text of a trailing
+// "//" comment, ignoring slashes inside string literals.
+func splitComment(text string) (string, string) {
+	var quote rune
+	for i, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote && (i == 0 || text[i-1] != '\\') {
+				quote = 0
+			}
+		case r == '"' || r == '\'' || r == '`':
+			quote = r
+		case strings.HasPrefix(text[i:], "//"):
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+2:])
+		}
+	}
+	return strings.TrimSpace(text), ""
+}
+
+// parseCall parses a call expression such as "Add(3, 4)" into the function
+// name and its evaluated arguments.
+func parseCall(call string) (string, []any, error) {
+	expr, err := parser.ParseExpr(call)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid example call %q: %w", call, err)
+	}
+
+	callExpr, ok := expr.(*ast.CallExpr)
+	if !ok {
+		return "", nil, fmt.Errorf("example %q is not a function call", call)
+	}
+
+	var function string
+	switch fun := callExpr.Fun.(type) {
+	case *ast.Ident:
+		function = fun.Name
+	case *ast.SelectorExpr:
+		function = fun.Sel.Name
+	default:
+		return "", nil, fmt.Errorf("example %q does not call a named function", call)
+	}
+
+	args := make([]any, 0, len(callExpr.Args))
+	for _, arg := range callExpr.Args {
+		value, err := evalConstant(arg)
+		if err != nil {
+			return "", nil, fmt.Errorf("example %q: %w", call, err)
+		}
+		args = append(args, value)
+	}
+
+	return function, args, nil
+}
+
+// parseValues parses a comma-separated list of constant expressions.
+func parseValues(list string) ([]any, error) {
+	if list == "" {
+		return nil, fmt.Errorf("no values given")
+	}
+	_, values, err := parseCall("values(" + list + ")")
+	return values, err
+}
+
+// evalConstant evaluates a constant expression built from literals, math
+// constants, parentheses and arithmetic operators.
+func evalConstant(expr ast.Expr) (any, error) {
+	value, err := constantValue(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	switch value.Kind() {
+	case constant.Bool:
+		return constant.BoolVal(value), nil
+	case constant.String:
+		return constant.StringVal(value), nil
+	case constant.Int:
+		if i, exact := constant.Int64Val(value); exact {
+			return int(i), nil
+		}
+	}
+
+	f, _ := constant.Float64Val(value)
+	return f, nil
+}
+
+func constantValue(expr ast.Expr) (constant.Value, error) {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		value := constant.MakeFromLiteral(e.Value, e.Kind, 0)
+		switch value.Kind() {
+		case constant.Unknown:
+			return nil, fmt.Errorf("invalid literal %s", e.Value)
+		case constant.Complex:
+			return nil, fmt.Errorf("unsupported complex literal %s", e.Value)
+		}
+		return value, nil
+	case *ast.Ident:
+		switch e.Name {
+		case "true", "false":
+			return constant.MakeBool(e.Name == "true"), nil
+		}
+	case *ast.SelectorExpr:
+		if pkg, ok := e.X.(*ast.Ident); ok {
+			if value, ok := mathConstants[pkg.Name+"."+e.Sel.Name]; ok {
+				return constant.MakeFloat64(value), nil
+			}
+		}
+	case *ast.ParenExpr:
+		return constantValue(e.X)
+	case *ast.UnaryExpr:
+		x, err := constantValue(e.X)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case (e.Op == token.SUB || e.Op == token.ADD) && isNumber(x), e.Op == token.NOT && x.Kind() == constant.Bool:
+			return constant.UnaryOp(e.Op, x, 0), nil
+		case e.Op == token.SUB || e.Op == token.ADD || e.Op == token.NOT:
+			return nil, fmt.Errorf("invalid operation %s%s", e.Op, x)
+		}
+	case *ast.BinaryExpr:
+		x, err := constantValue(e.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := constantValue(e.Y)
+		if err != nil {
+			return nil, err
+		}
+		switch e.Op {
+		case token.ADD, token.SUB, token.MUL, token.QUO:
+			bothStrings := e.Op == token.ADD && x.Kind() == constant.String && y.Kind() == constant.String
+			if !bothStrings && !(isNumber(x) && isNumber(y)) {
+				return nil, fmt.Errorf("invalid operation %s %s %s", x, e.Op, y)
+			}
+		}
+		switch e.Op {
+		case token.ADD, token.SUB, token.MUL:
+			return constant.BinaryOp(x, e.Op, y), nil
+		case token.QUO:
+			if constant.Sign(y) == 0 {
+				return nil, fmt.Errorf("division by zero in constant expression")
+			}
+			// Force float division so that "1 / 2" means 0.5, as a reader would expect.
+			return constant.BinaryOp(constant.ToFloat(x), token.QUO, constant.ToFloat(y)), nil
+		}
+	}
+
+	return nil, fmt.Errorf("unsupported expression in example: %T", expr)
+}
+
+// isNumber reports whether a constant is an integer or floating-point number.
+func isNumber(value constant.Value) bool {
+	return value.Kind() == constant.Int || value.Kind() == constant.Float
+}
@@ -2,22 +2,64 @@ package metadata
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go/ast"
 	"go/build"
-	"go/doc"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"io/fs"
 	"regexp"
+	"sort"
 	"strings"
 )
 
-func ExtractMetadata(importPath, name string) (FunctionMetaData, error) {
-	doc, err := getDocumentation(importPath, name)
+var ErrInvalidDocumentation = errors.New("invalid documentation")
+
+// Option configures ExtractMetadata.
+type Option func(*options)
+
+type options struct {
+	strict bool
+}
+
+// Strict makes ExtractMetadata fail when the documentation contains unknown
+// or malformed tags instead of only reporting them as diagnostics.
+func Strict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// ExtractMetadata extracts the metadata of a function or type from its documentation.
+// Problems found in the documentation are reported in FunctionMetaData.Diagnostics;
+// in strict mode any error-level diagnostic also causes ErrInvalidDocumentation.
+func ExtractMetadata(importPath, name string, opts ...Option) (FunctionMetaData, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	entry, err := getDocumentation(importPath, name)
 	if err != nil {
 		return FunctionMetaData{}, err
 	}
 
-	meta := parseDocumentation(name, doc)
+	meta := parseDocumentation(name, entry)
+
+	if o.strict {
+		var problems []string
+		for _, d := range meta.Diagnostics {
+			if d.Severity == SeverityError {
+				problems = append(problems, d.String())
+			}
+		}
+		if len(problems) > 0 {
+			return meta, fmt.Errorf("%w: %s", ErrInvalidDocumentation, strings.Join(problems, "; "))
+		}
+	}
+
 	return meta, nil
 }
 
@@ -25,10 +67,47 @@ func ExtractMetadata(importPath, name string) (FunctionMetaData, error) {
 type FunctionMetaData struct {
 	FunctionName string       `json:"function_name"`
 	Description  string       `json:"description"`
+	TypeParams   []TypeParam  `json:"type_params,omitempty"` // Type parameters of a generic function
 	Params       []Param      `json:"params"`
 	Return       []ReturnType `json:"return"`
-	Examples     []string     `json:"examples"`
+	Examples     []Example    `json:"examples"`
 	Constraints  []Constraint `json:"constraints"`
+	Errors       []string     `json:"errors,omitempty"`       // Error conditions documented with @errors
+	See          []string     `json:"see,omitempty"`          // Related functions or references from @see
+	Deprecated   string       `json:"deprecated,omitempty"`   // Deprecation notice, if any
+	Since        string       `json:"since,omitempty"`        // Version the function was introduced in
+	SideEffects  []string     `json:"side_effects,omitempty"` // What the function changes outside itself, from @sideeffect
+	Dangerous    string       `json:"dangerous,omitempty"`    // Why the function is dangerous to run, from @dangerous
+	Pure         bool         `json:"pure,omitempty"`         // The result only depends on the arguments, from @pure
+	Diagnostics  []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// Tags returns the traits of the function that policies can refer to:
+// "sideeffect", "dangerous", "deprecated", "generic" and "pure".
+func (m FunctionMetaData) Tags() []string {
+	var tags []string
+	if len(m.SideEffects) > 0 {
+		tags = append(tags, "sideeffect")
+	}
+	if m.Dangerous != "" {
+		tags = append(tags, "dangerous")
+	}
+	if m.Deprecated != "" {
+		tags = append(tags, "deprecated")
+	}
+	if len(m.TypeParams) > 0 {
+		tags = append(tags, "generic")
+	}
+	if m.Pure {
+		tags = append(tags, "pure")
+	}
+	return tags
+}
+
+// RequiresApproval reports whether the function has side effects or is
+// dangerous, so that a call should be approved before it runs.
+func (m FunctionMetaData) RequiresApproval() bool {
+	return len(m.SideEffects) > 0 || m.Dangerous != ""
 }
 
 // Constraint represents a constraint on the function or its parameters.
@@ -37,16 +116,26 @@ type Constraint struct {
 	Desc      string `json:"desc"`
 }
 
+// TypeParam represents a type parameter of a generic function and its constraint.
+type TypeParam struct {
+	Name       string `json:"name"`
+	Constraint string `json:"constraint"` // The constraint as written in the source (e.g., "cmp.Ordered")
+}
+
 // Param represents a function parameter.
 type Param struct {
-	Name string `json:"name"`
-	Desc string `json:"desc"`
+	Name    string   `json:"name"`
+	Desc    string   `json:"desc"`
+	Default string   `json:"default,omitempty"` // Value used when the caller has no preference
+	Enum    []string `json:"enum,omitempty"`    // Allowed values, if the parameter is restricted
+	Unit    string   `json:"unit,omitempty"`    // Unit of measure (e.g., "radians")
 }
 
 // ReturnType represents the return type and its description.
 type ReturnType struct {
-	Type        string `json:"type"`        // The return type (e.g., "float64")
-	Description string `json:"description"` // A description of the return value
+	Name        string `json:"name,omitempty"` // The result name, from "@return name type:" or the Go signature
+	Type        string `json:"type"`           // The return type (e.g., "float64")
+	Description string `json:"description"`    // A description of the return value
 }
 
 // ToJSON converts the FunctionMetaData struct to a JSON-formatted string.
@@ -58,101 +147,357 @@ func (meta FunctionMetaData) ToJSON() (string, error) {
 	return string(jsonData), nil
 }
 
-// parseDocumentation parses the documentation string and extracts metadata.
-func parseDocumentation(functionName, doc string) FunctionMetaData {
+// docTag is a single @tag entry, with any continuation lines already joined.
+//
+// Tags follow the grammar "@name [subject]: text". The subject is optional for
+// tags that describe the whole function (e.g., "@since: v1.2").
+type docTag struct {
+	Name      string
+	Subject   string
+	Text      string
+	Malformed bool // The line starts with "@" but does not follow the tag grammar
+	Pos       token.Position
+}
+
+// docLine is a single line of a doc comment together with its source position.
+type docLine struct {
+	Text string
+	Pos  token.Position
+}
+
+// docEntry is the documentation of a declaration as found in the source.
+type docEntry struct {
+	Lines []docLine
+	Decl  *ast.FuncDecl // Nil when the entry documents a type
+}
+
+// tagRegex splits a tag line into its name, optional subject and text.
+var tagRegex = regexp.MustCompile(`^@(\w+)(?:\s+([^:]+?))?\s*:\s*(.*)$`)
+
+// parseDocumentation parses the documentation of a declaration and extracts metadata.
+func parseDocumentation(functionName string, entry docEntry) FunctionMetaData {
 	meta := FunctionMetaData{
 		FunctionName: functionName,
 	}
 
-	// Extract description (the first line of the doc string)
-	lines := strings.Split(doc, "\n")
-	if len(lines) > 0 {
-		meta.Description = strings.TrimSpace(lines[0])
+	description, tags := splitDocumentation(entry.Lines)
+	meta.Description = description
+
+	var diags diagnostics
+	checkTags(&diags, tags)
+
+	// Parameter attributes may appear before the @param they refer to,
+	// so they are applied once all parameters are known.
+	var attributes []docTag
+
+	for _, tag := range tags {
+		if tag.Malformed {
+			continue
+		}
+
+		switch tag.Name {
+		case "param":
+			meta.Params = append(meta.Params, Param{Name: tag.Subject, Desc: tag.Text})
+		case "return":
+			ret := ReturnType{Type: tag.Subject, Description: tag.Text}
+			if name, typ, ok := strings.Cut(tag.Subject, " "); ok && token.IsIdentifier(name) {
+				ret.Name, ret.Type = name, strings.TrimSpace(typ)
+			}
+			meta.Return = append(meta.Return, ret)
+		case "constraint":
+			meta.Constraints = append(meta.Constraints, Constraint{Condition: tag.Subject, Desc: tag.Text})
+		case "example":
+			// An example that cannot be parsed is still shown to the model as written.
+			example, err := parseExample(tag.Subject, tag.Text)
+			if err != nil {
+				diags.errorf(tag, "%v", err)
+			}
+			meta.Examples = append(meta.Examples, example)
+		case "default", "enum", "unit":
+			attributes = append(attributes, tag)
+		case "deprecated":
+			meta.Deprecated = tag.Text
+		case "since":
+			meta.Since = tag.Text
+		case "errors":
+			meta.Errors = append(meta.Errors, tag.Text)
+		case "see":
+			meta.See = append(meta.See, splitList(tag.Text)...)
+		case "sideeffect":
+			meta.SideEffects = append(meta.SideEffects, tag.Text)
+		case "dangerous":
+			meta.Dangerous = tag.Text
+		case "pure":
+			meta.Pure = true
+		}
 	}
 
-	// Regex patterns
-	paramRegex := regexp.MustCompile(`@param (\w+): (.+)`)
-	returnRegex := regexp.MustCompile(`@return (\w+): (.+)`) // Updated to capture type and description
-	constraintRegex := regexp.MustCompile(`@constraint (.+): (.+)`)
-	exampleRegex := regexp.MustCompile(`@example:\s*(.+)`)
+	for _, tag := range attributes {
+		param := findParam(meta.Params, tag.Subject)
+		if param == nil {
+			diags.errorf(tag, "@%s refers to undocumented parameter %q", tag.Name, tag.Subject)
+			continue
+		}
+		switch tag.Name {
+		case "default":
+			param.Default = tag.Text
+		case "enum":
+			param.Enum = splitList(tag.Text)
+		case "unit":
+			param.Unit = tag.Text
+		}
+	}
 
-	// Parse the doc string line by line
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+	if entry.Decl != nil {
+		meta.TypeParams = typeParams(entry.Decl)
+		checkSignature(&diags, meta, entry, tags)
+		meta.Return = mergeResults(meta.Return, entry.Decl)
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		return diags[i].Pos.Line < diags[j].Pos.Line
+	})
+	meta.Diagnostics = diags
+	return meta
+}
+
+// typeParams lists the type parameters declared by a generic function.
+func typeParams(decl *ast.FuncDecl) []TypeParam {
+	if decl.Type.TypeParams == nil {
+		return nil
+	}
 
-		// Extract parameters
-		if matches := paramRegex.FindStringSubmatch(line); len(matches) == 3 {
-			meta.Params = append(meta.Params, Param{
-				Name: matches[1],
-				Desc: matches[2],
+	var params []TypeParam
+	for _, field := range decl.Type.TypeParams.List {
+		for _, name := range field.Names {
+			params = append(params, TypeParam{
+				Name:       name.Name,
+				Constraint: types.ExprString(field.Type),
 			})
 		}
+	}
+	return params
+}
 
-		// Extract return values
-		if matches := returnRegex.FindStringSubmatch(line); len(matches) == 3 {
-			meta.Return = append(meta.Return, ReturnType{
-				Type:        matches[1], // Capture the return type
-				Description: matches[2], // Capture the return description
-			})
+// mergeResults completes the documented results with the Go signature: named
+// results supply names missing from @return tags, and undocumented results
+// are appended with their type. A trailing error result is not included.
+func mergeResults(documented []ReturnType, decl *ast.FuncDecl) []ReturnType {
+	if decl.Type.Results == nil {
+		return documented
+	}
+
+	var signature []ReturnType
+	fields := decl.Type.Results.List
+	for i, field := range fields {
+		typ := types.ExprString(field.Type)
+		if typ == "error" && i == len(fields)-1 {
+			break
+		}
+		if len(field.Names) == 0 {
+			signature = append(signature, ReturnType{Type: typ})
 		}
+		for _, name := range field.Names {
+			result := ReturnType{Name: name.Name, Type: typ}
+			if result.Name == "_" {
+				result.Name = ""
+			}
+			signature = append(signature, result)
+		}
+	}
+
+	for i, result := range signature {
+		if i >= len(documented) {
+			documented = append(documented, result)
+			continue
+		}
+		if documented[i].Name == "" {
+			documented[i].Name = result.Name
+		}
+	}
 
-		// Extract constraints
-		if matches := constraintRegex.FindStringSubmatch(line); len(matches) == 3 {
-			meta.Constraints = append(meta.Constraints, Constraint{
-				Condition: matches[1],
-				Desc:      matches[2],
+	return documented
+}
+
+// splitDocumentation separates the free-form description block from the @tag
+// entries. Lines that follow a tag and do not start a new one are treated as
+// continuations of that tag until a blank line is reached. A conventional Go
+// "Deprecated:" paragraph is recognised as an @deprecated tag.
+func splitDocumentation(lines []docLine) (string, []docTag) {
+	var (
+		description []string
+		tags        []docTag
+		current     *docTag
+	)
+
+	for _, docLine := range lines {
+		line := strings.TrimSpace(docLine.Text)
+
+		switch {
+		case strings.HasPrefix(line, "@"):
+			tag := docTag{Pos: docLine.Pos}
+			if matches := tagRegex.FindStringSubmatch(line); matches != nil {
+				tag.Name = matches[1]
+				tag.Subject = strings.TrimSpace(matches[2])
+				tag.Text = matches[3]
+			} else {
+				tag.Name = strings.TrimPrefix(strings.Fields(line)[0], "@")
+				tag.Malformed = line != "@"+tag.Name || knownTags[tag.Name] != subjectFlag
+			}
+			tags = append(tags, tag)
+			current = &tags[len(tags)-1]
+		case strings.HasPrefix(line, "Deprecated:"):
+			tags = append(tags, docTag{
+				Name: "deprecated",
+				Text: strings.TrimSpace(strings.TrimPrefix(line, "Deprecated:")),
+				Pos:  docLine.Pos,
 			})
+			current = &tags[len(tags)-1]
+		case line == "":
+			current = nil
+			if len(tags) == 0 {
+				description = append(description, "")
+			}
+		case current != nil:
+			current.Text = strings.TrimSpace(current.Text + " " + line)
+		case len(tags) == 0:
+			description = append(description, line)
 		}
+	}
+
+	return joinParagraphs(description), tags
+}
 
-		// Extract examples
-		if matches := exampleRegex.FindStringSubmatch(line); len(matches) == 2 {
-			meta.Examples = append(meta.Examples, matches[1])
+// joinParagraphs joins wrapped lines into paragraphs separated by a blank line.
+func joinParagraphs(lines []string) string {
+	var paragraphs []string
+	var current []string
+
+	flush := func() {
+		if len(current) > 0 {
+			paragraphs = append(paragraphs, strings.Join(current, " "))
+			current = nil
 		}
 	}
 
-	return meta
+	for _, line := range lines {
+		if line == "" {
+			flush()
+			continue
+		}
+		current = append(current, line)
+	}
+	flush()
+
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// splitList splits a comma-separated tag value into trimmed, non-empty items.
+func splitList(text string) []string {
+	var items []string
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// findParam returns a pointer to the parameter with the given name, or nil.
+func findParam(params []Param, name string) *Param {
+	for i := range params {
+		if params[i].Name == name {
+			return &params[i]
+		}
+	}
+	return nil
 }
 
 // getDocumentation retrieves the documentation for a function or type in a package.
-func getDocumentation(importPath, name string) (string, error) {
+func getDocumentation(importPath, name string) (docEntry, error) {
 	// Create a new file set.
 	fset := token.NewFileSet()
 
 	// Locate the package directory using go/build.
 	pkg, err := build.Import(importPath, "", build.FindOnly)
 	if err != nil {
-		return "", fmt.Errorf("failed to locate package: %v", err)
+		return docEntry{}, fmt.Errorf("failed to locate package: %v", err)
 	}
 
-	// Parse the package directory.
-	pkgs, err := parser.ParseDir(fset, pkg.Dir, nil, parser.ParseComments)
+	// Parse the package directory, skipping test files.
+	pkgs, err := parser.ParseDir(fset, pkg.Dir, func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.ParseComments)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse package: %v", err)
+		return docEntry{}, fmt.Errorf("failed to parse package: %v", err)
 	}
 
-	// Iterate over the packages (usually just one).
+	// Iterate over the packages (usually just one). The AST is searched
+	// directly rather than through go/doc, which detaches the comments from
+	// their declarations and so loses their positions.
 	for _, pkg := range pkgs {
-		// Create a new doc.Package from the parsed package.
-		docPkg := doc.New(pkg, importPath, doc.AllDecls)
-
-		// Search for the type or function in the package.
-		for _, t := range docPkg.Types {
-			if t.Name == name {
-				return t.Doc, nil
-			}
-			for _, method := range t.Methods {
-				if method.Name == name {
-					return method.Doc, nil
+		for _, file := range pkg.Files {
+			for _, decl := range file.Decls {
+				switch d := decl.(type) {
+				case *ast.FuncDecl:
+					if d.Name.Name == name {
+						return docEntry{Lines: commentLines(fset, d.Doc), Decl: d}, nil
+					}
+				case *ast.GenDecl:
+					for _, spec := range d.Specs {
+						if ts, ok := spec.(*ast.TypeSpec); ok && ts.Name.Name == name {
+							doc := ts.Doc
+							if doc == nil {
+								doc = d.Doc
+							}
+							return docEntry{Lines: commentLines(fset, doc)}, nil
+						}
+					}
 				}
 			}
 		}
+	}
+
+	return docEntry{}, fmt.Errorf("function or type '%s' not found in package '%s'", name, importPath)
+}
+
+// commentLines splits a comment group into lines, stripping the comment
+// markers and skipping directives such as "//go:generate".
+func commentLines(fset *token.FileSet, group *ast.CommentGroup) []docLine {
+	if group == nil {
+		return nil
+	}
+
+	var lines []docLine
+	for _, comment := range group.List {
+		pos := fset.Position(comment.Pos())
 
-		for _, fun := range docPkg.Funcs {
-			if fun.Name == name {
-				return fun.Doc, nil
+		if text, ok := strings.CutPrefix(comment.Text, "//"); ok {
+			if isDirective(text) {
+				continue
 			}
+			lines = append(lines, docLine{Text: strings.TrimPrefix(text, " "), Pos: pos})
+			continue
+		}
+
+		text := strings.TrimSuffix(strings.TrimPrefix(comment.Text, "/*"), "*/")
+		for i, line := range strings.Split(text, "\n") {
+			linePos := pos
+			linePos.Line += i
+			if i > 0 {
+				linePos.Column = 1
+			}
+			lines = append(lines, docLine{Text: strings.TrimPrefix(strings.TrimSpace(line), "* "), Pos: linePos})
 		}
 	}
 
-	return "", fmt.Errorf("function or type '%s' not found in package '%s'", name, importPath)
+	return lines
+}
+
+// directiveRegex matches tool directives such as "//go:embed" or "//nolint:errcheck".
+var directiveRegex = regexp.MustCompile(`^[a-z0-9]+:[a-z0-9]`)
+
+// isDirective reports whether a line comment is a tool directive.
+func isDirective(text string) bool {
+	return directiveRegex.MatchString(text)
 }
@@ -0,0 +1,49 @@
+package metadata
+
+import (
+	"fmt"
+	"go/build"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"strings"
+)
+
+// PackageMetaData describes a package from its package comment.
+type PackageMetaData struct {
+	ImportPath string `json:"import_path"`
+	Name       string `json:"name"`
+	Doc        string `json:"doc"`      // Full package comment
+	Synopsis   string `json:"synopsis"` // First sentence of the package comment
+}
+
+// ExtractPackageMetadata reads the package comment of the package at importPath.
+func ExtractPackageMetadata(importPath string) (PackageMetaData, error) {
+	pkg, err := build.Import(importPath, "", build.FindOnly)
+	if err != nil {
+		return PackageMetaData{}, fmt.Errorf("failed to locate package: %v", err)
+	}
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, pkg.Dir, func(fi fs.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return PackageMetaData{}, fmt.Errorf("failed to parse package: %v", err)
+	}
+
+	meta := PackageMetaData{ImportPath: importPath}
+	for name, p := range pkgs {
+		meta.Name = name
+		for _, file := range p.Files {
+			if file.Doc != nil {
+				meta.Doc = strings.TrimSpace(file.Doc.Text())
+				break
+			}
+		}
+	}
+	meta.Synopsis = new(doc.Package).Synopsis(meta.Doc)
+
+	return meta, nil
+}
@@ -0,0 +1,300 @@
+// Package policy controls which callers may run which tools, and with which
+// arguments, using declarative rules loaded from YAML or JSON.
+//
+// A policy is a list of rules checked in order; the first rule matching a
+// call decides it, and calls no rule matches get the default effect:
+//
+//	default: allow
+//	rules:
+//	  - name: factorial-limit
+//	    effect: deny
+//	    callers: [tenant-a]
+//	    tools: [Factorial]
+//	    arguments:
+//	      n: {gt: 170}
+//	    reason: Factorial is limited to n <= 170 for tenant A
+//	  - name: no-dangerous-tools
+//	    effect: deny
+//	    tags: [dangerous]
+//
+// Callers match the caller's ID or any of its groups; requests without a
+// caller match "anonymous". Tools are glob
+// patterns as understood by path.Match, matched against the tool name without
+// type arguments. Tags are the traits returned by
+// metadata.FunctionMetaData.Tags. Empty lists match everything, but a rule
+// must have at least one condition: a rule meant to match every call says so
+// with tools: ["*"].
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-agent/agent"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ErrInvalidPolicy = errors.New("invalid policy")
+
+// anonymous is the caller name matched by requests without a caller.
+const anonymous = "anonymous"
+
+// Effect is the outcome of a rule.
+type Effect string
+
+const (
+	Allow Effect = "allow"
+	Deny  Effect = "deny"
+)
+
+// Policy is a list of rules and the effect applied when none matches.
+type Policy struct {
+	Default Effect `json:"default" yaml:"default"`
+	Rules   []Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule applies its effect to the calls it matches.
+type Rule struct {
+	Name      string               `json:"name" yaml:"name"`
+	Effect    Effect               `json:"effect" yaml:"effect"`
+	Callers   []string             `json:"callers,omitempty" yaml:"callers,omitempty"`
+	Tools     []string             `json:"tools,omitempty" yaml:"tools,omitempty"`
+	Tags      []string             `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Arguments map[string]Condition `json:"arguments,omitempty" yaml:"arguments,omitempty"` // Conditions on arguments, by parameter name
+	Reason    string               `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// Condition restricts the value of an argument. All given bounds must hold
+// for the condition to match.
+type Condition struct {
+	Min   *float64 `json:"min,omitempty" yaml:"min,omitempty"` // Value >= Min
+	Max   *float64 `json:"max,omitempty" yaml:"max,omitempty"` // Value <= Max
+	GT    *float64 `json:"gt,omitempty" yaml:"gt,omitempty"`   // Value > GT
+	LT    *float64 `json:"lt,omitempty" yaml:"lt,omitempty"`   // Value < LT
+	In    []any    `json:"in,omitempty" yaml:"in,omitempty"`   // Value is one of In
+	NotIn []any    `json:"not_in,omitempty" yaml:"not_in,omitempty"`
+}
+
+// Denial is the error returned for a denied call. It wraps agent.ErrDenied.
+type Denial struct {
+	Rule     string `json:"rule"`               // Name of the deciding rule; empty for the default
+	Caller   string `json:"caller"`             // ID of the caller
+	Tool     string `json:"tool"`               // Name of the tool
+	Argument string `json:"argument,omitempty"` // Argument whose condition matched, if any
+	Reason   string `json:"reason"`
+}
+
+func (d *Denial) Error() string {
+	rule := d.Rule
+	if rule == "" {
+		rule = "default"
+	}
+	return fmt.Sprintf("%v: %s (rule %s)", agent.ErrDenied, d.Reason, rule)
+}
+
+func (d *Denial) Unwrap() error {
+	return agent.ErrDenied
+}
+
+// Load reads a policy from a .yaml, .yml or .json file.
+func Load(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var p Policy
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&p)
+	default:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrInvalidPolicy, file, err)
+	}
+
+	return &p, p.Validate()
+}
+
+// Validate checks the effects, conditions and tool patterns of the policy.
+func (p *Policy) Validate() error {
+	if p.Default == "" {
+		p.Default = Allow
+	}
+	if p.Default != Allow && p.Default != Deny {
+		return fmt.Errorf("%w: unknown default effect %q", ErrInvalidPolicy, p.Default)
+	}
+
+	for i, rule := range p.Rules {
+		name := rule.Name
+		if name == "" {
+			name = "#" + strconv.Itoa(i+1)
+		}
+		if rule.Effect != Allow && rule.Effect != Deny {
+			return fmt.Errorf("%w: rule %s: unknown effect %q", ErrInvalidPolicy, name, rule.Effect)
+		}
+		if len(rule.Callers) == 0 && len(rule.Tools) == 0 && len(rule.Tags) == 0 && len(rule.Arguments) == 0 {
+			return fmt.Errorf("%w: rule %s has no conditions; use tools: [\"*\"] to match every call", ErrInvalidPolicy, name)
+		}
+		for argument, condition := range rule.Arguments {
+			if condition.empty() {
+				return fmt.Errorf("%w: rule %s: empty condition on argument %q", ErrInvalidPolicy, name, argument)
+			}
+		}
+		for _, pattern := range rule.Tools {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("%w: rule %s: bad tool pattern %q", ErrInvalidPolicy, name, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// Authorize implements agent.Authorizer.
+func (p *Policy) Authorize(ctx context.Context, req agent.AuthorizationRequest) error {
+	for _, rule := range p.Rules {
+		argument, ok := rule.matches(req)
+		if !ok {
+			continue
+		}
+		if rule.Effect == Allow {
+			return nil
+		}
+
+		reason := rule.Reason
+		if reason == "" {
+			reason = fmt.Sprintf("%s may not call %s", callerName(req.Caller), req.Tool)
+		}
+		return &Denial{Rule: rule.Name, Caller: req.Caller.ID, Tool: req.Tool, Argument: argument, Reason: reason}
+	}
+
+	if p.Default == Deny {
+		return &Denial{Caller: req.Caller.ID, Tool: req.Tool, Reason: fmt.Sprintf("no rule allows %s to call %s", callerName(req.Caller), req.Tool)}
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to the call and, if it matched
+// on an argument condition, the name of that argument.
+func (r Rule) matches(req agent.AuthorizationRequest) (string, bool) {
+	id := req.Caller.ID
+	if id == "" {
+		id = anonymous
+	}
+	if len(r.Callers) > 0 && !slices.ContainsFunc(r.Callers, func(c string) bool {
+		return c == id || slices.Contains(req.Caller.Groups, c)
+	}) {
+		return "", false
+	}
+
+	if len(r.Tools) > 0 && !slices.ContainsFunc(r.Tools, func(pattern string) bool {
+		matched, _ := path.Match(pattern, req.Tool)
+		return matched
+	}) {
+		return "", false
+	}
+
+	tags := req.Metadata.Tags()
+	if len(r.Tags) > 0 && !slices.ContainsFunc(r.Tags, func(tag string) bool { return slices.Contains(tags, tag) }) {
+		return "", false
+	}
+
+	names := make([]string, 0, len(r.Arguments))
+	for name := range r.Arguments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var matched string
+	for _, name := range names {
+		value, ok := argument(req, name)
+		if !ok || !r.Arguments[name].matches(value) {
+			return "", false
+		}
+		matched = name
+	}
+	return matched, true
+}
+
+// argument finds an argument by parameter name, or by position as "arg1", "arg2", ...
+func argument(req agent.AuthorizationRequest, name string) (any, bool) {
+	for i, arg := range req.Args {
+		if arg.Name == name || "arg"+strconv.Itoa(i+1) == name {
+			return arg.Value, true
+		}
+	}
+	return nil, false
+}
+
+// empty reports whether the condition has no bounds, so that it would match
+// any value.
+func (c Condition) empty() bool {
+	return c.Min == nil && c.Max == nil && c.GT == nil && c.LT == nil && len(c.In) == 0 && len(c.NotIn) == 0
+}
+
+func (c Condition) matches(value any) bool {
+	number, isNumber := toFloat(value)
+	bound := func(limit *float64, holds func(float64, float64) bool) bool {
+		return limit == nil || (isNumber && holds(number, *limit))
+	}
+
+	return bound(c.Min, func(v, l float64) bool { return v >= l }) &&
+		bound(c.Max, func(v, l float64) bool { return v <= l }) &&
+		bound(c.GT, func(v, l float64) bool { return v > l }) &&
+		bound(c.LT, func(v, l float64) bool { return v < l }) &&
+		(len(c.In) == 0 || contains(c.In, value)) &&
+		(len(c.NotIn) == 0 || !contains(c.NotIn, value))
+}
+
+func contains(list []any, value any) bool {
+	number, isNumber := toFloat(value)
+	for _, item := range list {
+		if n, ok := toFloat(item); ok && isNumber && n == number {
+			return true
+		}
+		if fmt.Sprint(item) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func toFloat(v any) (float64, bool) {
+	if number, ok := v.(json.Number); ok {
+		f, err := number.Float64()
+		return f, err == nil
+	}
+
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	}
+	return 0, false
+}
+
+func callerName(caller agent.Caller) string {
+	if caller.ID == "" {
+		return anonymous + " callers"
+	}
+	return caller.ID
+}
@@ -0,0 +1,192 @@
+package policy
+
+import (
+	"errors"
+	"go-agent/agent"
+	"go-agent/metadata"
+	"go-agent/tools/evaluation"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func float(v float64) *float64 {
+	return &v
+}
+
+func TestRuleMatches(t *testing.T) {
+	tenant := agent.Caller{ID: "alice", Groups: []string{"tenant-a"}}
+	generic := agent.AuthorizationRequest{
+		Tool:     "Max",
+		Metadata: metadata.FunctionMetaData{FunctionName: "Max", TypeParams: []metadata.TypeParam{{Name: "T", Constraint: "cmp.Ordered"}}},
+	}
+	factorial := func(caller agent.Caller, n any) agent.AuthorizationRequest {
+		return agent.AuthorizationRequest{
+			Caller: caller,
+			Tool:   "Factorial",
+			Args:   []evaluation.NamedValue{{Name: "n", Type: "int", Value: n}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		rule     Rule
+		req      agent.AuthorizationRequest
+		matched  bool
+		argument string
+	}{
+		{"caller by ID", Rule{Callers: []string{"alice"}}, factorial(tenant, 3), true, ""},
+		{"caller by group", Rule{Callers: []string{"tenant-a"}}, factorial(tenant, 3), true, ""},
+		{"other caller", Rule{Callers: []string{"bob"}}, factorial(tenant, 3), false, ""},
+		{"anonymous", Rule{Callers: []string{"anonymous"}}, factorial(agent.Caller{}, 3), true, ""},
+		{"anonymous rule, named caller", Rule{Callers: []string{"anonymous"}}, factorial(tenant, 3), false, ""},
+		{"tool name", Rule{Tools: []string{"Add", "Factorial"}}, factorial(tenant, 3), true, ""},
+		{"tool glob", Rule{Tools: []string{"Fact*"}}, factorial(tenant, 3), true, ""},
+		{"other tool", Rule{Tools: []string{"Add"}}, factorial(tenant, 3), false, ""},
+		{"generic tool by base name", Rule{Tools: []string{"Max"}}, generic, true, ""},
+		{"generic tag", Rule{Tags: []string{"pure", "generic"}}, generic, true, ""},
+		{
+			"tag",
+			Rule{Tags: []string{"dangerous"}},
+			agent.AuthorizationRequest{Tool: "Delete", Metadata: metadata.FunctionMetaData{Dangerous: "deletes files"}},
+			true, "",
+		},
+		{"missing tag", Rule{Tags: []string{"dangerous"}}, factorial(tenant, 3), false, ""},
+		{"argument above bound", Rule{Arguments: map[string]Condition{"n": {GT: float(170)}}}, factorial(tenant, 171), true, "n"},
+		{"argument within bound", Rule{Arguments: map[string]Condition{"n": {GT: float(170)}}}, factorial(tenant, 170), false, ""},
+		{"argument by position", Rule{Arguments: map[string]Condition{"arg1": {Max: float(5)}}}, factorial(tenant, 5), true, "arg1"},
+		{"missing argument", Rule{Arguments: map[string]Condition{"x": {Min: float(0)}}}, factorial(tenant, 5), false, ""},
+		{"non-numeric argument", Rule{Arguments: map[string]Condition{"n": {Min: float(0)}}}, factorial(tenant, "five"), false, ""},
+		{"argument in list", Rule{Arguments: map[string]Condition{"n": {In: []any{1, 2, 3}}}}, factorial(tenant, 2.0), true, "n"},
+		{"argument not in list", Rule{Arguments: map[string]Condition{"n": {NotIn: []any{1, 2, 3}}}}, factorial(tenant, 2), false, ""},
+		{
+			"all conditions",
+			Rule{Callers: []string{"tenant-a"}, Tools: []string{"Factorial"}, Arguments: map[string]Condition{"n": {Min: float(10), LT: float(20)}}},
+			factorial(tenant, 15),
+			true, "n",
+		},
+		{
+			"one condition fails",
+			Rule{Callers: []string{"tenant-a"}, Tools: []string{"Factorial"}, Arguments: map[string]Condition{"n": {Min: float(10), LT: float(20)}}},
+			factorial(tenant, 20),
+			false, "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			argument, matched := tt.rule.matches(tt.req)
+			if matched != tt.matched || argument != tt.argument {
+				t.Errorf("matches = (%q, %v), want (%q, %v)", argument, matched, tt.argument, tt.matched)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		rules   int
+		err     string // Substring of the error; empty for success
+	}{
+		{
+			name: "yaml",
+			file: "policy.yaml",
+			content: `default: deny
+rules:
+  - name: all
+    effect: allow
+    tools: ["*"]
+`,
+			rules: 1,
+		},
+		{
+			name:    "json",
+			file:    "policy.json",
+			content: `{"rules": [{"name": "limit", "effect": "deny", "arguments": {"n": {"gt": 170}}}]}`,
+			rules:   1,
+		},
+		{
+			name: "unknown yaml field",
+			file: "policy.yaml",
+			content: `rules:
+  - name: typo
+    effect: deny
+    tool: [Factorial]
+`,
+			err: "field tool not found",
+		},
+		{
+			name:    "unknown json field",
+			file:    "policy.json",
+			content: `{"rules": [{"name": "typo", "effect": "deny", "tool": ["Factorial"]}]}`,
+			err:     `unknown field "tool"`,
+		},
+		{
+			name:    "no conditions",
+			file:    "policy.yaml",
+			content: "rules:\n  - name: everything\n    effect: deny\n",
+			err:     "rule everything has no conditions",
+		},
+		{
+			name:    "empty argument condition",
+			file:    "policy.yaml",
+			content: "rules:\n  - effect: deny\n    arguments:\n      n: {in: []}\n",
+			err:     `rule #1: empty condition on argument "n"`,
+		},
+		{
+			name:    "unknown effect",
+			file:    "policy.yaml",
+			content: "rules:\n  - name: maybe\n    effect: perhaps\n    tools: [Add]\n",
+			err:     `unknown effect "perhaps"`,
+		},
+		{
+			name:    "bad tool pattern",
+			file:    "policy.yaml",
+			content: "rules:\n  - name: bad\n    effect: deny\n    tools: [\"[\"]\n",
+			err:     "bad tool pattern",
+		},
+		{
+			name:    "unknown default",
+			file:    "policy.json",
+			content: `{"default": "perhaps"}`,
+			err:     `unknown default effect "perhaps"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(file, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := Load(file)
+			if tt.err != "" {
+				if !errors.Is(err, ErrInvalidPolicy) || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Load error = %v, want %v containing %q", err, ErrInvalidPolicy, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if len(p.Rules) != tt.rules {
+				t.Errorf("got %d rules, want %d", len(p.Rules), tt.rules)
+			}
+		})
+	}
+}
+
+func TestLoadExample(t *testing.T) {
+	p, err := Load("example.yaml")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if p.Default != Allow {
+		t.Errorf("default = %q, want %q", p.Default, Allow)
+	}
+}
@@ -0,0 +1,172 @@
+// Package prompt manages the templates the agent renders into LLM prompts.
+//
+// Templates are named and versioned. A Registry loads them from a directory
+// laid out as <name>/<version>.tmpl, e.g. call/v1.tmpl, answer/v2.tmpl or
+// tool/Divide/v1.tmpl, and refers to them as "name@version", or just "name"
+// for the latest version.
+package prompt
+
+import (
+	"errors"
+	"fmt"
+	"go-agent/metadata"
+	"io"
+	"sync"
+	"text/template"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("prompt template not found")
+	ErrInvalidTemplate  = errors.New("invalid prompt template")
+)
+
+// Well-known template names.
+const (
+	Call   = "call"   // Asks the LLM for a function call; rendered with Data
+	Answer = "answer" // Asks the LLM to answer the user; rendered with AnswerData
+
+	// ToolPrefix starts the name of a per-tool override such as
+	// "tool/Divide", which replaces the generated documentation of that tool
+	// in Data.Tools. It is rendered with Tool.
+	ToolPrefix = "tool/"
+)
+
+// Data is passed to call templates.
+type Data struct {
+	Persona     string // Who the assistant is and what it does
+	Domain      string // What the tools are for, e.g. from their package comments
+	UserRequest string
+	Tools       string    // Documentation of all tools, combined
+	ToolList    []Tool    // The same tools, one by one
+	Schema      string    // JSON schema of a valid function call
+	Examples    string    // Few-shot demonstrations, combined
+	ExampleList []Example // The same demonstrations, one by one
+	History     []Turn    // Earlier requests, oldest first
+	Date        string    // Today's date as YYYY-MM-DD
+}
+
+// Tool describes a tool to a call template.
+type Tool struct {
+	Name          string
+	Doc           string // Generated documentation, or the tool's override
+	Metadata      metadata.FunctionMetaData
+	TypeArguments []string // Available instantiations of a generic tool
+}
+
+// Example is a few-shot demonstration of a request and the call answering it.
+type Example struct {
+	Request string
+	Call    string // JSON function call
+}
+
+// Turn is an earlier request handled by the agent.
+type Turn struct {
+	Request string
+	Call    string // JSON function call, if one was made
+	Result  string // JSON result, if the tool succeeded
+	Error   string
+}
+
+// AnswerData is passed to answer templates.
+type AnswerData struct {
+	UserRequest string
+	Call        string // JSON function call
+	Result      string // JSON result, if the tool succeeded
+	Error       string // Error returned by the tool
+	Date        string
+}
+
+// Template is a parsed prompt template.
+type Template struct {
+	Name    string
+	Version string
+	Text    string
+
+	tmpl *template.Template
+}
+
+// maxCached bounds the templates kept by Parse; the oldest is dropped first.
+const maxCached = 64
+
+// cacheKey identifies a parsed template by its name and text.
+type cacheKey struct {
+	name string
+	text string
+}
+
+var (
+	cacheMu    sync.Mutex
+	cache      = make(map[cacheKey]*template.Template)
+	cacheOrder []cacheKey // Oldest first
+)
+
+// Parse parses a template, reusing the result of an earlier call with the
+// same name and text. Missing map keys are errors, so that typos are caught by
+// Validate.
+func Parse(name, text string) (*Template, error) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	key := cacheKey{name: name, text: text}
+	tmpl, ok := cache[key]
+	if !ok {
+		var err error
+		tmpl, err = template.New(name).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("%w %s: %w", ErrInvalidTemplate, name, err)
+		}
+		if len(cacheOrder) >= maxCached {
+			delete(cache, cacheOrder[0])
+			cacheOrder = cacheOrder[1:]
+		}
+		cache[key] = tmpl
+		cacheOrder = append(cacheOrder, key)
+	}
+
+	return &Template{Name: name, Text: text, tmpl: tmpl}, nil
+}
+
+// Execute renders the template with data.
+func (t *Template) Execute(w io.Writer, data any) error {
+	return t.tmpl.Execute(w, data)
+}
+
+// Validate renders the template with sample data of the kind it will receive,
+// reporting references to fields that do not exist.
+func (t *Template) Validate(sample any) error {
+	if err := t.tmpl.Execute(io.Discard, sample); err != nil {
+		return fmt.Errorf("%w %s: %w", ErrInvalidTemplate, t.Ref(), err)
+	}
+	return nil
+}
+
+// Ref returns the reference of the template, e.g. "call@v2".
+func (t *Template) Ref() string {
+	if t.Version == "" {
+		return t.Name
+	}
+	return t.Name + "@" + t.Version
+}
+
+// SampleData returns data of the kind passed to templates with the given
+// name, for use with Validate.
+func SampleData(name string) any {
+	switch {
+	case name == Answer:
+		return AnswerData{UserRequest: "What is 3 plus 4?", Call: `{"function":"Add","arguments":[3,4]}`, Result: `{"result":7}`, Date: "2006-01-02"}
+	case len(name) > len(ToolPrefix) && name[:len(ToolPrefix)] == ToolPrefix:
+		return Tool{Name: name[len(ToolPrefix):], Doc: "Function: " + name[len(ToolPrefix):] + "\n"}
+	default:
+		return Data{
+			Persona:     "You are a helpful assistant.",
+			Domain:      "Package calculator provides arithmetic operations.",
+			UserRequest: "What is 3 plus 4?",
+			Tools:       "Function: Add\n",
+			ToolList:    []Tool{{Name: "Add", Doc: "Function: Add\n"}},
+			Schema:      "{}",
+			ExampleList: []Example{{Request: "What is 1 plus 2?", Call: `{"function":"Add","arguments":[1,2]}`}},
+			History:     []Turn{{Request: "What is 1 plus 2?", Call: `{"function":"Add","arguments":[1,2]}`, Result: `{"result":3}`}},
+			Date:        "2006-01-02",
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package prompt
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed templates
+var defaultTemplates embed.FS
+
+// Registry holds named, versioned templates.
+type Registry struct {
+	mu        sync.RWMutex
+	templates map[string][]*Template // Sorted by version, oldest first
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{templates: make(map[string][]*Template)}
+}
+
+// Default returns a registry with the templates built into the agent.
+func Default() *Registry {
+	sub, err := fs.Sub(defaultTemplates, "templates")
+	if err != nil {
+		panic(err)
+	}
+	r, err := LoadFS(sub)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Load reads every <name>/<version>.tmpl file below dir. The built-in
+// templates are included, so a directory only needs to add or override
+// versions.
+func Load(dir string) (*Registry, error) {
+	r := Default()
+	return r, r.loadFS(os.DirFS(dir))
+}
+
+// LoadFS reads every <name>/<version>.tmpl file in fsys into a new Registry.
+func LoadFS(fsys fs.FS) (*Registry, error) {
+	r := NewRegistry()
+	return r, r.loadFS(fsys)
+}
+
+func (r *Registry) loadFS(fsys fs.FS) error {
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(p) != ".tmpl" {
+			return err
+		}
+
+		name, file := path.Split(p)
+		name = strings.TrimSuffix(name, "/")
+		if name == "" {
+			return fmt.Errorf("%w: %s is not in a <name>/<version>.tmpl layout", ErrInvalidTemplate, p)
+		}
+
+		text, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+
+		_, err = r.Register(name, strings.TrimSuffix(file, ".tmpl"), string(text))
+		return err
+	})
+}
+
+// Register parses and validates a template and adds it to the registry,
+// replacing an existing template with the same name and version.
+func (r *Registry) Register(name, version, text string) (*Template, error) {
+	t, err := Parse(name, text)
+	if err != nil {
+		return nil, err
+	}
+	t.Version = version
+	if err := t.Validate(SampleData(name)); err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	versions := r.templates[name]
+	for i, existing := range versions {
+		if existing.Version == version {
+			versions[i] = t
+			return t, nil
+		}
+	}
+	versions = append(versions, t)
+	sort.Slice(versions, func(i, j int) bool { return compareVersions(versions[i].Version, versions[j].Version) < 0 })
+	r.templates[name] = versions
+
+	return t, nil
+}
+
+// Get returns the template for a reference such as "call@v2", or the latest
+// version for a bare name such as "call".
+func (r *Registry) Get(ref string) (*Template, error) {
+	name, version, _ := strings.Cut(ref, "@")
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := r.templates[name]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, ref)
+	}
+	if version == "" {
+		return versions[len(versions)-1], nil
+	}
+	for _, t := range versions {
+		if t.Version == version {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, ref)
+}
+
+// Names returns the names of all templates in sorted order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Versions returns the versions of a template, oldest first.
+func (r *Registry) Versions(name string) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var versions []string
+	for _, t := range r.templates[name] {
+		versions = append(versions, t.Version)
+	}
+	return versions
+}
+
+// ToolOverrides returns the latest per-tool override of every tool that has
+// one, keyed by tool name.
+func (r *Registry) ToolOverrides() map[string]*Template {
+	overrides := make(map[string]*Template)
+	for _, name := range r.Names() {
+		if tool, ok := strings.CutPrefix(name, ToolPrefix); ok {
+			overrides[tool], _ = r.Get(name)
+		}
+	}
+	return overrides
+}
+
+// compareVersions orders versions such as "v2" before "v10" by comparing
+// their dot-separated numeric parts, falling back to string order.
+func compareVersions(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aerr := strconv.Atoi(as[i])
+		bn, berr := strconv.Atoi(bs[i])
+		if aerr != nil || berr != nil {
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+			continue
+		}
+		if an != bn {
+			return an - bn
+		}
+	}
+	return len(as) - len(bs)
+}
@@ -0,0 +1,140 @@
+package recording
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"go-agent/agent"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Recorder is an agent.Observer that assembles a Trace for every request
+// handled by Execute or Respond and passes it to a save function once the
+// request finishes.
+//
+// Events are matched to traces by the request text, so concurrent runs of
+// the same request are merged into one trace.
+type Recorder struct {
+	save func(*Trace)
+
+	mu     sync.Mutex
+	active map[string]*Trace
+}
+
+// NewRecorder creates a Recorder that calls save with each finished trace.
+func NewRecorder(save func(*Trace)) *Recorder {
+	return &Recorder{save: save, active: make(map[string]*Trace)}
+}
+
+// SaveToDir returns a save function that writes each trace to its own JSON
+// file in dir, logging failures to logger.
+func SaveToDir(dir string, logger *slog.Logger) func(*Trace) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return func(t *Trace) {
+		path, err := Write(dir, t)
+		if err != nil {
+			logger.Warn("Failed to write trace", "request", t.Request, "error", err)
+			return
+		}
+		logger.Debug("Trace written", "path", path)
+	}
+}
+
+// Write stores the trace in dir as "<time>-<id>.json" and returns the path.
+func Write(dir string, t *Trace) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	data, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error encoding trace: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.json", t.Started.Format("20060102T150405.000"), t.ID))
+	return path, os.WriteFile(path, data, 0o644)
+}
+
+func (r *Recorder) OnEvent(e agent.Event) {
+	if finished := r.record(e); finished != nil {
+		r.save(finished)
+	}
+}
+
+// record adds the event to its trace and returns the trace once it is finished.
+func (r *Recorder) record(e agent.Event) *Trace {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if e.Type == agent.EventRequestStarted {
+		r.active[e.Request] = &Trace{ID: newID(), Request: e.Request, Started: e.Time}
+		return nil
+	}
+
+	t, ok := r.active[e.Request]
+	if !ok {
+		return nil
+	}
+
+	switch e.Type {
+	case agent.EventPromptBuilt:
+		t.Generations = append(t.Generations, &Generation{Phase: e.Phase, Prompt: e.Prompt})
+	case agent.EventToken:
+		if g := t.generation(e.Phase); g != nil {
+			g.Tokens = append(g.Tokens, e.Token)
+		}
+	case agent.EventGenerationDone:
+		if g := t.generation(e.Phase); g != nil {
+			g.Text = e.Text
+			g.FirstToken = e.TTFT
+			g.Duration = e.Duration
+			g.Finish = e.Finish
+			g.Usage = e.Usage
+		}
+	case agent.EventCallParsed:
+		t.Calls = append(t.Calls, e.Call)
+	case agent.EventRetry:
+		t.Retries = append(t.Retries, Retry{Attempt: e.Attempt, Error: e.Err.Error(), ErrorClass: agent.ErrorClass(e.Err)})
+	case agent.EventApprovalDecided:
+		t.Tool = &ToolRun{Name: e.Tool, Converted: e.Converted, Approval: e.Approval}
+	case agent.EventToolRejected:
+		if t.Tool == nil || t.Tool.Name != e.Tool {
+			t.Tool = &ToolRun{Name: e.Tool}
+		}
+		t.Tool.Error = e.Err.Error()
+		t.Tool.ErrorClass = agent.ToolErrorClass(e.Err)
+	case agent.EventToolFinished:
+		approval := t.Tool.approval(e.Tool)
+		t.Tool = &ToolRun{Name: e.Tool, Args: e.Args, Converted: e.Converted, Duration: e.Duration, Approval: approval}
+		if e.Err != nil {
+			t.Tool.Error = e.Err.Error()
+			t.Tool.ErrorClass = agent.ToolErrorClass(e.Err)
+		} else {
+			t.Tool.Result = e.Result
+			t.Tool.Cached = e.Result != nil && e.Result.Cached
+		}
+	case agent.EventRequestFinished:
+		delete(r.active, e.Request)
+		t.Duration = e.Duration
+		if e.Err != nil {
+			t.Error = e.Err.Error()
+			t.ErrorClass = agent.ErrorClass(e.Err)
+		}
+		return t
+	}
+
+	return nil
+}
+
+func newID() string {
+	b := make([]byte, 6)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,97 @@
+package recording
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go-agent/agent"
+)
+
+// Difference is a field whose value changed between two traces.
+type Difference struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// Replay runs the request of a recorded trace through the agent again and
+// returns the new trace. The agent may use a different engine or prompt
+// than the recorded run.
+func Replay(ctx context.Context, a *agent.Agent, recorded *Trace) (*Trace, error) {
+	return Capture(ctx, a, recorded.Request)
+}
+
+// Capture runs a request through the agent with Respond and returns its
+// trace. Failures are part of the outcome and are recorded in the trace.
+func Capture(ctx context.Context, a *agent.Agent, request string) (*Trace, error) {
+	done := make(chan *Trace, 1)
+	unsubscribe := a.Subscribe(NewRecorder(func(t *Trace) { done <- t }))
+	defer unsubscribe()
+
+	a.Respond(ctx, request)
+
+	select {
+	case t := <-done:
+		return t, nil
+	default:
+		return nil, fmt.Errorf("no trace recorded for %q", request)
+	}
+}
+
+// Diff compares the outcome of two traces: the prompt, the function call,
+// the converted arguments, the result, the errors and the answer.
+func Diff(old, new *Trace) []Difference {
+	var diffs []Difference
+	compare := func(field, o, n string) {
+		if o != n {
+			diffs = append(diffs, Difference{Field: field, Old: o, New: n})
+		}
+	}
+
+	compare("prompt", old.Prompt(agent.PhaseCall), new.Prompt(agent.PhaseCall))
+	compare("function", callFunction(old.Call()), callFunction(new.Call()))
+	compare("arguments", callArguments(old.Call()), callArguments(new.Call()))
+	compare("retries", fmt.Sprint(len(old.Retries)), fmt.Sprint(len(new.Retries)))
+
+	oldTool, newTool := old.Tool, new.Tool
+	if oldTool == nil {
+		oldTool = &ToolRun{}
+	}
+	if newTool == nil {
+		newTool = &ToolRun{}
+	}
+	compare("converted", toJSON(oldTool.Converted), toJSON(newTool.Converted))
+	compare("result", toJSON(oldTool.Result), toJSON(newTool.Result))
+	compare("tool_error", oldTool.Error, newTool.Error)
+
+	compare("error", old.Error, new.Error)
+	compare("answer", old.Answer(), new.Answer())
+
+	return diffs
+}
+
+func callFunction(call *agent.FunctionCall) string {
+	if call == nil {
+		return ""
+	}
+	return call.Function
+}
+
+func callArguments(call *agent.FunctionCall) string {
+	if call == nil {
+		return ""
+	}
+	return toJSON(call.Arguments)
+}
+
+// toJSON renders a value for comparison; nil and empty values render as "".
+func toJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	if s := string(data); s != "null" && s != "[]" {
+		return s
+	}
+	return ""
+}
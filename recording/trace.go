@@ -0,0 +1,125 @@
+// Package recording persists each agent run as a JSON trace and replays
+// traces against a different engine or prompt to compare the outcomes.
+package recording
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-agent/agent"
+	"go-agent/llm"
+	"go-agent/tools/evaluation"
+	"os"
+	"time"
+)
+
+// Trace is the record of one request handled by the agent. Durations are in
+// nanoseconds.
+type Trace struct {
+	ID          string                `json:"id"`
+	Request     string                `json:"request"`
+	Started     time.Time             `json:"started"`
+	Duration    time.Duration         `json:"duration"`
+	Generations []*Generation         `json:"generations"`
+	Calls       []*agent.FunctionCall `json:"calls,omitempty"`   // Every call parsed, including retried ones
+	Retries     []Retry               `json:"retries,omitempty"` // Why the LLM was asked again
+	Tool        *ToolRun              `json:"tool,omitempty"`    // The tool that was finally run
+	Error       string                `json:"error,omitempty"`   // Why the request failed
+	ErrorClass  string                `json:"error_class,omitempty"`
+}
+
+// Generation is one pass of the LLM: the rendered prompt and the raw tokens
+// it produced.
+type Generation struct {
+	Phase      agent.Phase      `json:"phase"`
+	Prompt     string           `json:"prompt"`
+	Tokens     []string         `json:"tokens"`
+	Text       string           `json:"text"`
+	FirstToken time.Duration    `json:"first_token"`
+	Duration   time.Duration    `json:"duration"`
+	Finish     llm.FinishReason `json:"finish_reason,omitempty"`
+	Usage      llm.Usage        `json:"usage"`
+}
+
+// Retry records a reply that was rejected and asked for again.
+type Retry struct {
+	Attempt    int    `json:"attempt"`
+	Error      string `json:"error"`
+	ErrorClass string `json:"error_class"`
+}
+
+// ToolRun records the evaluation of a tool.
+type ToolRun struct {
+	Name       string                  `json:"name"`
+	Args       []any                   `json:"args"`
+	Converted  []evaluation.NamedValue `json:"converted,omitempty"`
+	Approval   *agent.Decision         `json:"approval,omitempty"` // Set for tools that require approval
+	Result     *evaluation.Result      `json:"result,omitempty"`
+	Cached     bool                    `json:"cached,omitempty"` // The result came from the cache of pure tools
+	Error      string                  `json:"error,omitempty"`
+	ErrorClass string                  `json:"error_class,omitempty"`
+	Duration   time.Duration           `json:"duration"`
+}
+
+// approval returns the approval recorded for the named tool, if any.
+func (r *ToolRun) approval(name string) *agent.Decision {
+	if r == nil || r.Name != name {
+		return nil
+	}
+	return r.Approval
+}
+
+// Call returns the function call the agent acted on, if any.
+func (t *Trace) Call() *agent.FunctionCall {
+	if len(t.Calls) == 0 {
+		return nil
+	}
+	return t.Calls[len(t.Calls)-1]
+}
+
+// Prompt returns the last prompt rendered for the given phase.
+func (t *Trace) Prompt(phase agent.Phase) string {
+	if g := t.generation(phase); g != nil {
+		return g.Prompt
+	}
+	return ""
+}
+
+// Answer returns the natural-language answer, if one was synthesized or
+// given by the LLM instead of a call.
+func (t *Trace) Answer() string {
+	if call := t.Call(); call != nil && call.Declined() {
+		return call.Answer
+	}
+	if g := t.generation(agent.PhaseAnswer); g != nil {
+		return g.Text
+	}
+	return ""
+}
+
+func (t *Trace) generation(phase agent.Phase) *Generation {
+	for i := len(t.Generations) - 1; i >= 0; i-- {
+		if t.Generations[i].Phase == phase {
+			return t.Generations[i]
+		}
+	}
+	return nil
+}
+
+// Load reads a trace written by a Recorder. Numbers are kept as json.Number
+// so that arguments convert exactly as they did when recorded.
+func Load(path string) (*Trace, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var t Trace
+	if err := decoder.Decode(&t); err != nil {
+		return nil, fmt.Errorf("invalid trace %s: %w", path, err)
+	}
+	return &t, nil
+}
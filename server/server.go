@@ -0,0 +1,138 @@
+// Package server exposes an agent over HTTP.
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-agent/agent"
+	"go-agent/policy"
+	"go-agent/tools/evaluation"
+	"net/http"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// ExecuteRequest is the body of a POST /v1/execute call.
+type ExecuteRequest struct {
+	Request string `json:"request"`
+}
+
+// ExecuteResponse is the reply to a POST /v1/execute call. Error holds the
+// error returned by the tool, or the reason the request failed.
+type ExecuteResponse struct {
+	Request    string              `json:"request"`
+	Call       *agent.FunctionCall `json:"call,omitempty"`
+	Result     *evaluation.Result  `json:"result,omitempty"`
+	Answer     string              `json:"answer,omitempty"`
+	Error      string              `json:"error,omitempty"`
+	ErrorClass string              `json:"error_class,omitempty"`
+	Denial     *policy.Denial      `json:"denial,omitempty"` // Why a policy denied the tool call
+}
+
+// Server serves an agent's requests and its metrics.
+type Server struct {
+	agent    *agent.Agent
+	gatherer prometheus.Gatherer
+	mux      *http.ServeMux
+}
+
+// New creates a Server for the agent. Metrics are gathered from gatherer and
+// served on /metrics; pass nil to leave the endpoint out.
+func New(a *agent.Agent, gatherer prometheus.Gatherer) *Server {
+	s := &Server{agent: a, gatherer: gatherer, mux: http.NewServeMux()}
+
+	s.mux.HandleFunc("POST /v1/execute", s.handleExecute)
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	if gatherer != nil {
+		s.mux.Handle("GET /metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{}))
+	}
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleExecute(w http.ResponseWriter, r *http.Request) {
+	var body ExecuteRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Request == "" {
+		writeJSON(w, http.StatusBadRequest, ExecuteResponse{Error: "body must be a JSON object with a non-empty \"request\""})
+		return
+	}
+
+	response, err := s.agent.Respond(agent.WithCaller(r.Context(), callerOf(r)), body.Request)
+	if err != nil {
+		writeJSON(w, statusFor(err), ExecuteResponse{
+			Request:    body.Request,
+			Error:      err.Error(),
+			ErrorClass: agent.ErrorClass(err),
+		})
+		return
+	}
+
+	reply := ExecuteResponse{
+		Request: response.Request,
+		Call:    response.Call,
+		Answer:  response.Answer,
+	}
+	switch {
+	case response.Call.Declined():
+	case response.Err != nil:
+		reply.Error = response.Err.Error()
+		reply.ErrorClass = agent.ToolErrorClass(response.Err)
+		errors.As(response.Err, &reply.Denial)
+	default:
+		reply.Result = &response.Result
+	}
+	writeJSON(w, http.StatusOK, reply)
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok\n"))
+}
+
+// callerOf identifies the caller from the X-Caller-ID header and the
+// comma-separated X-Caller-Groups header. Authenticating the caller is left
+// to a proxy in front of the server.
+func callerOf(r *http.Request) agent.Caller {
+	caller := agent.Caller{ID: r.Header.Get("X-Caller-ID")}
+	for _, group := range strings.Split(r.Header.Get("X-Caller-Groups"), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			caller.Groups = append(caller.Groups, group)
+		}
+	}
+	return caller
+}
+
+// statusFor picks the HTTP status for a request that failed before a tool ran.
+func statusFor(err error) int {
+	switch {
+	case errors.Is(err, agent.ErrUnknownFunction), errors.Is(err, agent.ErrInvalidResponse):
+		return http.StatusUnprocessableEntity
+	case agent.ErrorClass(err) == "timeout":
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// writeJSON encodes v before writing the status, so that a value that cannot
+// be encoded is reported as a 500 rather than an empty reply.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		body.Reset()
+		json.NewEncoder(&body).Encode(ExecuteResponse{Error: fmt.Sprintf("error encoding response: %v", err)})
+		status = http.StatusInternalServerError
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body.Bytes())
+}
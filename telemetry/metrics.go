@@ -0,0 +1,134 @@
+package telemetry
+
+import (
+	"go-agent/agent"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics is an agent.Observer that records Prometheus metrics for the
+// requests, tool invocations, LLM generations and retries of an agent.
+type Metrics struct {
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	toolCalls       *prometheus.CounterVec
+	toolDuration    *prometheus.HistogramVec
+	cacheHits       *prometheus.CounterVec
+	firstToken      *prometheus.HistogramVec
+	generation      *prometheus.HistogramVec
+	tokens          *prometheus.CounterVec
+	generations     *prometheus.CounterVec
+	usage           *prometheus.CounterVec
+	retries         *prometheus.CounterVec
+}
+
+// llmBuckets cover latencies from a cached reply to a slow local model.
+var llmBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// NewMetrics creates the agent metrics and registers them with reg.
+func NewMetrics(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_requests_total",
+			Help: "User requests handled by the agent, by outcome.",
+		}, []string{"outcome"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "agent_request_duration_seconds",
+			Help:    "Time taken to handle a user request.",
+			Buckets: llmBuckets,
+		}, []string{"outcome"}),
+		toolCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_tool_invocations_total",
+			Help: "Tool invocations, by tool name and outcome.",
+		}, []string{"tool", "outcome"}),
+		cacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_tool_cache_hits_total",
+			Help: "Tool invocations answered from the result cache.",
+		}, []string{"tool"}),
+		toolDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "agent_tool_duration_seconds",
+			Help:    "Time taken by a tool invocation.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"tool"}),
+		firstToken: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "agent_llm_time_to_first_token_seconds",
+			Help:    "Latency until the LLM produced its first token.",
+			Buckets: llmBuckets,
+		}, []string{"phase"}),
+		generation: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "agent_llm_generation_duration_seconds",
+			Help:    "Total time taken by an LLM generation.",
+			Buckets: llmBuckets,
+		}, []string{"phase"}),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_llm_tokens_total",
+			Help: "Tokens generated by the LLM.",
+		}, []string{"phase"}),
+		generations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_llm_generations_total",
+			Help: "LLM generations, by phase and why they ended.",
+		}, []string{"phase", "finish_reason"}),
+		usage: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_llm_usage_tokens_total",
+			Help: "Prompt and completion tokens reported by the LLM engine.",
+		}, []string{"phase", "type"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "agent_retries_total",
+			Help: "Times the LLM was asked again for a function call, by the error that caused it.",
+		}, []string{"reason"}),
+	}
+
+	reg.MustRegister(m.requests, m.requestDuration, m.toolCalls, m.toolDuration, m.cacheHits,
+		m.firstToken, m.generation, m.tokens, m.generations, m.usage, m.retries)
+	return m
+}
+
+func (m *Metrics) OnEvent(e agent.Event) {
+	switch e.Type {
+	case agent.EventRequestFinished:
+		outcome := outcome(agent.ErrorClass(e.Err))
+		m.requests.WithLabelValues(outcome).Inc()
+		m.requestDuration.WithLabelValues(outcome).Observe(e.Duration.Seconds())
+	case agent.EventToolFinished:
+		tool := toolLabel(e)
+		m.toolCalls.WithLabelValues(tool, outcome(agent.ToolErrorClass(e.Err))).Inc()
+		m.toolDuration.WithLabelValues(tool).Observe(e.Duration.Seconds())
+		if e.Result != nil && e.Result.Cached {
+			m.cacheHits.WithLabelValues(tool).Inc()
+		}
+	case agent.EventToolRejected:
+		m.toolCalls.WithLabelValues(toolLabel(e), outcome(agent.ToolErrorClass(e.Err))).Inc()
+	case agent.EventGenerationDone:
+		phase := string(e.Phase)
+		if e.Tokens > 0 {
+			m.firstToken.WithLabelValues(phase).Observe(e.TTFT.Seconds())
+		}
+		m.generation.WithLabelValues(phase).Observe(e.Duration.Seconds())
+		m.tokens.WithLabelValues(phase).Add(float64(e.Tokens))
+		if e.Finish != "" {
+			m.generations.WithLabelValues(phase, string(e.Finish)).Inc()
+		}
+		m.usage.WithLabelValues(phase, "prompt").Add(float64(e.Usage.PromptTokens))
+		m.usage.WithLabelValues(phase, "completion").Add(float64(e.Usage.CompletionTokens))
+	case agent.EventRetry:
+		m.retries.WithLabelValues(agent.ErrorClass(e.Err)).Inc()
+	}
+}
+
+// toolLabel returns the tool name of an event, or "unknown" when the LLM named
+// a tool that is not registered, so that made-up names cannot grow the number
+// of label values without bound.
+func toolLabel(e agent.Event) string {
+	if agent.ErrorClass(e.Err) == "tool_not_found" {
+		return "unknown"
+	}
+	return e.Tool
+}
+
+// outcome turns an empty error class into "success".
+func outcome(class string) string {
+	if class == "" {
+		return "success"
+	}
+	return class
+}
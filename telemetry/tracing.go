@@ -0,0 +1,39 @@
+// Package telemetry configures the export of the agent's traces and metrics.
+package telemetry
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// SetupOTLP installs a global tracer provider that exports spans over
+// OTLP/HTTP. The exporter is configured through the standard environment
+// variables, e.g. OTEL_EXPORTER_OTLP_ENDPOINT. The returned function flushes
+// pending spans and must be called before the program exits.
+func SetupOTLP(ctx context.Context, serviceName string) (shutdown func(context.Context) error, err error) {
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	provider := NewTracerProvider(serviceName, sdktrace.WithBatcher(exporter))
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// NewTracerProvider creates a tracer provider for the service with the given
+// span processors. In tests, pass the agent a provider built with
+// sdktrace.WithSyncer(tracetest.NewInMemoryExporter()) to inspect its spans.
+func NewTracerProvider(serviceName string, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
+	opts = append(opts, sdktrace.WithResource(resource.NewSchemaless(
+		semconv.ServiceName(serviceName),
+	)))
+	return sdktrace.NewTracerProvider(opts...)
+}
@@ -0,0 +1,112 @@
+package telemetry_test
+
+import (
+	"context"
+	"go-agent/agent"
+	"go-agent/calculator"
+	"go-agent/llm"
+	"go-agent/telemetry"
+	"go-agent/tools/toolstore"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// replyEngine streams a fixed reply, one token per element.
+type replyEngine []string
+
+func (e replyEngine) GenerateTokens(ctx context.Context, prompt string) (*llm.Stream, error) {
+	stream, tokenChan := llm.NewStream()
+	go func() {
+		for _, token := range e {
+			tokenChan <- token
+		}
+		stream.Finish(llm.StreamEnd{Usage: llm.Usage{PromptTokens: 42, CompletionTokens: len(e)}})
+	}()
+	return stream, nil
+}
+
+func TestExecuteSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := telemetry.NewTracerProvider("go-agent-test", sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+
+	toolStore, err := toolstore.NewFunctionStoreFromPkg("go-agent/calculator", calculator.FunctionRegistry(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := agent.NewAgent(replyEngine{`{"function": "Add", `, `"arguments": [3, 4]}`}, toolStore)
+	a.TracerProvider = provider
+
+	result, err := a.Execute(context.Background(), "What is 3 plus 4?")
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if got := result.String(); got != `{"result":7}` {
+		t.Errorf("result = %s, want {\"result\":7}", got)
+	}
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+
+	want := map[string][]attribute.KeyValue{
+		"agent.execute":       {attribute.String("agent.request", "What is 3 plus 4?")},
+		"agent.render_prompt": nil,
+		"llm.generate_tokens": {
+			attribute.String("llm.phase", "call"),
+			attribute.Int("llm.tokens", 2),
+			attribute.String("llm.finish_reason", "stop"),
+			attribute.Int("llm.usage.prompt_tokens", 42),
+			attribute.Int("llm.usage.completion_tokens", 2),
+		},
+		"tool.evaluate": {
+			attribute.String("tool.name", "Add"),
+			attribute.Int("tool.argument_count", 2),
+			attribute.Bool("tool.cache_hit", false),
+		},
+	}
+	if len(spans) != len(want) {
+		t.Errorf("got spans %v, want %d spans", names(spans), len(want))
+	}
+
+	root, ok := spans["agent.execute"]
+	if !ok {
+		t.Fatalf("no agent.execute span in %v", names(spans))
+	}
+	for name, attributes := range want {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("no %s span in %v", name, names(spans))
+			continue
+		}
+		if name != "agent.execute" && span.Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("%s is not a child of agent.execute", name)
+		}
+		for _, attr := range attributes {
+			if !hasAttribute(span.Attributes, attr) {
+				t.Errorf("%s: missing attribute %s=%s in %v", name, attr.Key, attr.Value.Emit(), span.Attributes)
+			}
+		}
+	}
+}
+
+func hasAttribute(attributes []attribute.KeyValue, want attribute.KeyValue) bool {
+	for _, attr := range attributes {
+		if attr.Key == want.Key && attr.Value == want.Value {
+			return true
+		}
+	}
+	return false
+}
+
+func names(spans map[string]tracetest.SpanStub) []string {
+	var list []string
+	for name := range spans {
+		list = append(list, name)
+	}
+	return list
+}
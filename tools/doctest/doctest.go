@@ -0,0 +1,143 @@
+// Package doctest runs the @example annotations of registered tools and
+// checks the documented results against the actual ones.
+package doctest
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-agent/metadata"
+	"go-agent/tools/evaluation"
+	"go-agent/tools/toolstore"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// tolerance is the relative tolerance used when comparing numeric results,
+// since documented values such as "Log(2.71828) // returns 1" are rounded.
+const tolerance = 1e-6
+
+// Result is the outcome of running a single example.
+type Result struct {
+	Tool    string            `json:"tool"`
+	Example metadata.Example  `json:"example"`
+	Got     evaluation.Result `json:"got"`
+	Err     string            `json:"error,omitempty"`
+	Passed  bool              `json:"passed"`
+	Skipped bool              `json:"skipped"`          // The example documents no expected outcome
+	Reason  string            `json:"reason,omitempty"` // Why the example failed
+}
+
+// Report summarises the results of a doctest run.
+type Report struct {
+	Results []Result `json:"results"`
+}
+
+// Failed returns the results of all examples that did not pass.
+func (r Report) Failed() []Result {
+	var failed []Result
+	for _, result := range r.Results {
+		if !result.Passed && !result.Skipped {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Run evaluates every documented example of every tool in the store.
+func Run(store *toolstore.ToolStore) Report {
+	names := store.ListToolNames()
+	sort.Strings(names)
+
+	var report Report
+	for _, name := range names {
+		tool, err := store.GetTool(name)
+		if err != nil {
+			continue
+		}
+		for _, example := range tool.Metadata.Examples {
+			report.Results = append(report.Results, runExample(store, name, example))
+		}
+	}
+
+	return report
+}
+
+// runExample evaluates one example through the tool it calls.
+func runExample(store *toolstore.ToolStore, toolName string, example metadata.Example) Result {
+	result := Result{Tool: toolName, Example: example}
+
+	if example.Function == "" {
+		result.Reason = "example could not be parsed"
+		return result
+	}
+
+	if !example.HasExpectation() {
+		result.Skipped = true
+		return result
+	}
+
+	tool, err := store.GetTool(example.Function)
+	if err != nil {
+		result.Reason = fmt.Sprintf("example calls unknown function %q", example.Function)
+		return result
+	}
+
+	result.Got, err = tool.Evaluate(example.Args)
+	got := result.Got.Slice()
+	if err != nil {
+		result.Err = err.Error()
+	}
+
+	switch {
+	case example.ExpectedError != "":
+		if err == nil {
+			result.Reason = fmt.Sprintf("expected error %q, got none", example.ExpectedError)
+		} else if err.Error() != example.ExpectedError {
+			result.Reason = fmt.Sprintf("expected error %q, got %q", example.ExpectedError, err.Error())
+		}
+	case err != nil:
+		result.Reason = fmt.Sprintf("unexpected error: %v", err)
+	case len(got) != len(example.Expected):
+		result.Reason = fmt.Sprintf("expected %d result(s), got %d", len(example.Expected), len(got))
+	default:
+		for i := range got {
+			if !Equal(got[i], example.Expected[i]) {
+				result.Reason = fmt.Sprintf("result %d: expected %v, got %v", i+1, example.Expected[i], got[i])
+				break
+			}
+		}
+	}
+
+	result.Passed = result.Reason == ""
+	return result
+}
+
+// Equal compares two values, treating all numeric kinds, including
+// json.Number, as interchangeable and allowing a small relative tolerance.
+func Equal(got, expected any) bool {
+	g, gok := toFloat(got)
+	e, eok := toFloat(expected)
+	if gok && eok {
+		return math.Abs(g-e) <= tolerance*math.Max(1, math.Abs(e))
+	}
+	return reflect.DeepEqual(got, expected)
+}
+
+func toFloat(v any) (float64, bool) {
+	if number, ok := v.(json.Number); ok {
+		f, err := number.Float64()
+		return f, err == nil
+	}
+
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	}
+	return 0, false
+}
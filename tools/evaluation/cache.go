@@ -0,0 +1,187 @@
+package evaluation
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache remembers the results of pure tools, those tagged @pure, keyed by the
+// tool name and the converted arguments, so that Add(3, 4) and Add(3.0, 4)
+// share an entry. Only successful calls are cached. The least recently used
+// entries are evicted beyond the size limit, and entries expire after the TTL.
+type Cache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Most recently used first
+	hits    uint64
+	misses  uint64
+}
+
+type cacheEntry struct {
+	key     string
+	tool    string
+	result  Result
+	expires time.Time
+}
+
+// CacheStats reports the use of a Cache.
+type CacheStats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Entries int    `json:"entries"`
+}
+
+// NewCache creates a cache holding at most size results, each for at most
+// ttl. A size or ttl of zero means no limit.
+func NewCache(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// cacheKey canonicalizes a call. Arguments are encoded with their converted
+// types, so that 3 passed as an int and as a float64 do not collide.
+func cacheKey(tool string, args []NamedValue) (string, bool) {
+	canonical := make([][2]any, len(args))
+	for i, arg := range args {
+		canonical[i] = [2]any{arg.Type, arg.Value}
+	}
+	encoded, err := json.Marshal(canonical)
+	if err != nil {
+		return "", false
+	}
+	return tool + string(encoded), true
+}
+
+// Get returns the cached result of a call, marked as Cached.
+func (c *Cache) Get(tool string, args []NamedValue) (Result, bool) {
+	key, ok := cacheKey(tool, args)
+	if !ok {
+		return Result{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if ok && c.ttl > 0 && time.Now().After(element.Value.(*cacheEntry).expires) {
+		c.remove(element)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return Result{}, false
+	}
+
+	c.hits++
+	c.order.MoveToFront(element)
+	cached := element.Value.(*cacheEntry).result
+	result := Result{Values: append([]NamedValue(nil), cached.Values...), Cached: true}
+	return result, true
+}
+
+// Put stores the result of a call.
+func (c *Cache) Put(tool string, args []NamedValue, result Result) {
+	key, ok := cacheKey(tool, args)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{key: key, tool: tool, result: Result{Values: append([]NamedValue(nil), result.Values...)}}
+	if c.ttl > 0 {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	if c.size > 0 && c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+// Invalidate drops the results of a tool, including those of the
+// instantiations of a generic tool, e.g. "Max[int]" for "Max".
+func (c *Cache) Invalidate(tool string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, element := range c.entries {
+		name := element.Value.(*cacheEntry).tool
+		if name == tool || strings.HasPrefix(name, tool+"[") {
+			c.remove(element)
+		}
+	}
+}
+
+// Clear drops all results.
+func (c *Cache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// Stats returns the hit and miss counts and the number of cached results.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.order.Len()}
+}
+
+func (c *Cache) remove(element *list.Element) {
+	delete(c.entries, element.Value.(*cacheEntry).key)
+	c.order.Remove(element)
+}
+
+// Executor wraps next so that calls of pure tools are answered from the cache
+// when possible. Calls of other tools, and calls whose arguments do not
+// convert, go straight to next.
+func (c *Cache) Executor(next Executor) Executor {
+	return cachingExecutor{cache: c, next: next}
+}
+
+type cachingExecutor struct {
+	cache *Cache
+	next  Executor
+}
+
+func (e cachingExecutor) Execute(ctx context.Context, name string, tool Tool, args []interface{}) (Result, error) {
+	if !tool.Metadata.Pure {
+		return e.next.Execute(ctx, name, tool, args)
+	}
+
+	converted, err := tool.ConvertArguments(args)
+	if err != nil {
+		return e.next.Execute(ctx, name, tool, args)
+	}
+
+	if result, ok := e.cache.Get(name, converted); ok {
+		return result, nil
+	}
+
+	result, err := e.next.Execute(ctx, name, tool, args)
+	if err == nil {
+		e.cache.Put(name, converted, result)
+	}
+	return result, err
+}
@@ -0,0 +1,16 @@
+package evaluation
+
+import "context"
+
+// Executor runs the function of a tool. It decides where tools run: in the
+// calling process, or isolated in a sandbox.
+type Executor interface {
+	Execute(ctx context.Context, name string, tool Tool, args []interface{}) (Result, error)
+}
+
+// InProcess runs tools directly in the calling goroutine.
+type InProcess struct{}
+
+func (InProcess) Execute(ctx context.Context, name string, tool Tool, args []interface{}) (Result, error) {
+	return tool.Evaluate(args)
+}
@@ -0,0 +1,147 @@
+package evaluation
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Instantiations registers a generic function as a set of instantiations,
+// keyed by their type arguments. Go cannot call an uninstantiated generic
+// function through reflection, so each supported instantiation is listed
+// explicitly:
+//
+//	"Max": evaluation.Instantiations{
+//		"int":     calculator.Max[int],
+//		"float64": calculator.Max[float64],
+//	}
+//
+// An Instantiations value can be used as Tool.Function; the instantiation
+// is then selected from the argument types on each call. The ToolStore also
+// accepts a plain map[string]interface{} of the same form, so that packages
+// of tools need not depend on this one.
+type Instantiations map[string]interface{}
+
+// TypeArguments returns the registered type arguments in sorted order.
+func (in Instantiations) TypeArguments() []string {
+	keys := make([]string, 0, len(in))
+	for key := range in {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Instantiate returns the instantiation for the given type arguments, e.g. "int".
+func (in Instantiations) Instantiate(typeArgs string) (interface{}, error) {
+	function, ok := in[normalizeTypeArgs(typeArgs)]
+	if !ok {
+		return nil, fmt.Errorf("%w: [%s] is not registered, available: %s",
+			ErrNoInstantiation, typeArgs, strings.Join(in.TypeArguments(), ", "))
+	}
+	return function, nil
+}
+
+// Select picks the instantiation that best fits the argument types. Arguments
+// whose type matches a parameter exactly score highest, followed by integer
+// literals passed to integer parameters, then any other lossless conversion.
+// Ties are broken by the order of the type arguments.
+func (in Instantiations) Select(args []interface{}) (interface{}, error) {
+	var (
+		best      interface{}
+		bestScore = -1
+	)
+
+	for _, key := range in.TypeArguments() {
+		function := in[key]
+		functionType := reflect.TypeOf(function)
+		if functionType == nil || functionType.Kind() != reflect.Func {
+			return nil, fmt.Errorf("%w: instantiation [%s]", ErrNotAFunction, key)
+		}
+
+		score, ok := scoreArguments(args, functionType)
+		if ok && score > bestScore {
+			best, bestScore = function, score
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("%w: no instantiation among [%s] accepts %s",
+			ErrNoInstantiation, strings.Join(in.TypeArguments(), "], ["), describeArgs(args))
+	}
+	return best, nil
+}
+
+// scoreArguments rates how well the arguments fit a function type. It reports
+// false when any argument cannot be converted to its parameter type.
+func scoreArguments(args []interface{}, functionType reflect.Type) (int, bool) {
+	numIn := functionType.NumIn()
+	isVariadic := functionType.IsVariadic()
+
+	if (!isVariadic && len(args) != numIn) || (isVariadic && len(args) < numIn-1) {
+		return 0, false
+	}
+
+	total := 0
+	for i, arg := range args {
+		expectedType := functionType.In(min(i, numIn-1))
+		if isVariadic && i >= numIn-1 {
+			expectedType = expectedType.Elem()
+		}
+
+		score, ok := scoreArgument(arg, expectedType)
+		if !ok {
+			return 0, false
+		}
+		total += score
+	}
+	return total, true
+}
+
+func scoreArgument(arg interface{}, expectedType reflect.Type) (int, bool) {
+	if _, err := convertValue(arg, expectedType); err != nil {
+		return 0, false
+	}
+
+	kind := expectedType.Kind()
+	integerParam := kind >= reflect.Int && kind <= reflect.Uintptr
+
+	switch value := arg.(type) {
+	case json.Number:
+		_, err := value.Int64()
+		isInteger := err == nil
+		if isInteger == integerParam {
+			return 2, true
+		}
+		return 1, true
+	case float64:
+		if integerParam && value == math.Trunc(value) {
+			return 2, true
+		}
+	}
+
+	if reflect.TypeOf(arg) == expectedType {
+		return 3, true
+	}
+	return 1, true
+}
+
+// normalizeTypeArgs removes whitespace so that "int, string" matches "int,string".
+func normalizeTypeArgs(typeArgs string) string {
+	return strings.Join(strings.Fields(typeArgs), "")
+}
+
+func describeArgs(args []interface{}) string {
+	types := make([]string, len(args))
+	for i, arg := range args {
+		if number, ok := arg.(json.Number); ok {
+			types[i] = "number " + number.String()
+			continue
+		}
+		types[i] = fmt.Sprintf("%T", arg)
+	}
+	return "(" + strings.Join(types, ", ") + ")"
+}
@@ -0,0 +1,176 @@
+package evaluation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-agent/metadata"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// NamedValue is a single value returned by a tool, labelled with its name.
+type NamedValue struct {
+	Name  string `json:"name"`
+	Type  string `json:"type,omitempty"`
+	Value any    `json:"value"`
+}
+
+// MarshalJSON encodes a non-finite float value, which JSON cannot represent,
+// as the string "+Inf", "-Inf" or "NaN".
+func (v NamedValue) MarshalJSON() ([]byte, error) {
+	type plain NamedValue
+	p := plain(v)
+	p.Value = encodable(v.Value)
+	return json.Marshal(p)
+}
+
+// encodable replaces a non-finite float by its string form, as produced by
+// strconv.FormatFloat, and returns any other value unchanged.
+func encodable(value any) any {
+	var f float64
+	switch v := value.(type) {
+	case float64:
+		f = v
+	case float32:
+		f = float64(v)
+	default:
+		return value
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return value
+}
+
+// Result holds the values returned by a tool in declaration order. A trailing
+// error result is not part of it; it is returned separately by Evaluate.
+//
+// A Result serializes to a JSON object mapping each name to its value, e.g.
+// {"quotient": 3, "remainder": 2}, keeping the declaration order. Non-finite
+// floats, such as an overflowing Factorial, are encoded as the strings "+Inf",
+// "-Inf" and "NaN", and decode as such.
+type Result struct {
+	Values []NamedValue `json:"-"`
+	Cached bool         `json:"-"` // The values come from the Cache rather than a call
+}
+
+// newResult labels raw return values using the documented results. Values
+// without a documented or Go name are called "result", or "result1",
+// "result2", ... when there are several.
+func newResult(values []any, returns []metadata.ReturnType) Result {
+	result := Result{Values: make([]NamedValue, len(values))}
+	used := make(map[string]bool)
+
+	for i, value := range values {
+		named := NamedValue{Value: value, Type: fmt.Sprintf("%T", value)}
+		if i < len(returns) {
+			named.Name = returns[i].Name
+			if returns[i].Type != "" {
+				named.Type = returns[i].Type
+			}
+		}
+
+		if named.Name == "" || used[named.Name] {
+			named.Name = "result"
+			if len(values) > 1 {
+				named.Name = fmt.Sprintf("result%d", i+1)
+			}
+		}
+		used[named.Name] = true
+
+		result.Values[i] = named
+	}
+
+	return result
+}
+
+// Slice returns the bare values in declaration order.
+func (r Result) Slice() []any {
+	values := make([]any, len(r.Values))
+	for i, v := range r.Values {
+		values[i] = v.Value
+	}
+	return values
+}
+
+// Get returns the value with the given name.
+func (r Result) Get(name string) (any, bool) {
+	for _, v := range r.Values {
+		if v.Name == name {
+			return v.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Len returns the number of values.
+func (r Result) Len() int {
+	return len(r.Values)
+}
+
+// MarshalJSON encodes the result as an object whose keys keep the declaration order.
+func (r Result) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, v := range r.Values {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, err := json.Marshal(v.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(encodable(v.Value))
+		if err != nil {
+			return nil, fmt.Errorf("result %q: %w", v.Name, err)
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON decodes an object produced by MarshalJSON, preserving key order.
+func (r *Result) UnmarshalJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return fmt.Errorf("result must be a JSON object")
+	}
+
+	r.Values = nil
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		var value any
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+		r.Values = append(r.Values, NamedValue{Name: token.(string), Value: value})
+	}
+
+	_, err := decoder.Token()
+	return err
+}
+
+// String renders the result as JSON, which is also the form fed back to the model.
+func (r Result) String() string {
+	data, err := r.MarshalJSON()
+	if err != nil {
+		parts := make([]string, len(r.Values))
+		for i, v := range r.Values {
+			parts[i] = fmt.Sprintf("%s=%v", v.Name, v.Value)
+		}
+		return strings.Join(parts, ", ")
+	}
+	return string(data)
+}
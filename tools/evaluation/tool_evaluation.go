@@ -1,10 +1,13 @@
 package evaluation
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"go-agent/metadata"
+	"math"
 	"reflect"
+	"strconv"
 )
 
 var (
@@ -12,6 +15,7 @@ var (
 	ErrArgumentMismatch = errors.New("argument count mismatch")
 	ErrArgumentType     = errors.New("argument type mismatch")
 	ErrFunctionPanic    = errors.New("function execution panicked")
+	ErrNoInstantiation  = errors.New("no matching instantiation")
 )
 
 // Tool represents a function along with its metadata and documentation.
@@ -20,10 +24,55 @@ type Tool struct {
 	Function interface{}               `json:"function"`
 }
 
-func (t Tool) Evaluate(args []interface{}) ([]interface{}, error) {
+// Evaluate calls the tool's function with the given arguments and returns its
+// results labelled with the names from the tool's metadata.
+func (t Tool) Evaluate(args []interface{}) (Result, error) {
+	functionValue, argValues, err := t.prepare(args)
+	if err != nil {
+		return Result{}, err
+	}
+
+	results, err := callFunction(functionValue, argValues)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrFunctionPanic, err)
+	}
+
+	values, err := extractResults(results)
+	return newResult(values, t.Metadata.Return), err
+}
+
+// ConvertArguments converts the arguments the way Evaluate would, without
+// calling the function, and labels them with the documented parameter names.
+func (t Tool) ConvertArguments(args []interface{}) ([]NamedValue, error) {
+	_, argValues, err := t.prepare(args)
+	if err != nil {
+		return nil, err
+	}
+
+	converted := make([]NamedValue, len(argValues))
+	for i, value := range argValues {
+		converted[i] = NamedValue{Name: fmt.Sprintf("arg%d", i+1), Type: value.Type().String(), Value: value.Interface()}
+		if i < len(t.Metadata.Params) {
+			converted[i].Name = t.Metadata.Params[i].Name
+		}
+	}
+	return converted, nil
+}
+
+// prepare selects the function to call and converts the arguments to its
+// parameter types.
+func (t Tool) prepare(args []interface{}) (reflect.Value, []reflect.Value, error) {
+	if instantiations, ok := t.Function.(Instantiations); ok {
+		function, err := instantiations.Select(args)
+		if err != nil {
+			return reflect.Value{}, nil, err
+		}
+		return Tool{Metadata: t.Metadata, Function: function}.prepare(args)
+	}
+
 	functionValue := reflect.ValueOf(t.Function)
 	if functionValue.Kind() != reflect.Func {
-		return nil, ErrNotAFunction
+		return reflect.Value{}, nil, ErrNotAFunction
 	}
 
 	functionType := functionValue.Type()
@@ -32,25 +81,20 @@ func (t Tool) Evaluate(args []interface{}) ([]interface{}, error) {
 
 	if isVariadic {
 		if len(args) < numIn-1 {
-			return nil, fmt.Errorf("%w: expected at least %d arguments, got %d", ErrArgumentMismatch, numIn-1, len(args))
+			return reflect.Value{}, nil, fmt.Errorf("%w: expected at least %d arguments, got %d", ErrArgumentMismatch, numIn-1, len(args))
 		}
 	} else {
 		if len(args) != numIn {
-			return nil, fmt.Errorf("%w: expected %d arguments, got %d", ErrArgumentMismatch, numIn, len(args))
+			return reflect.Value{}, nil, fmt.Errorf("%w: expected %d arguments, got %d", ErrArgumentMismatch, numIn, len(args))
 		}
 	}
 
 	argValues, err := convertArguments(args, functionType)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrArgumentType, err)
+		return reflect.Value{}, nil, fmt.Errorf("%w: %v", ErrArgumentType, err)
 	}
 
-	results, err := callFunction(functionValue, argValues)
-	if err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrFunctionPanic, err)
-	}
-
-	return extractResults(results)
+	return functionValue, argValues, nil
 }
 
 // convertArguments converts and validates the provided arguments against the function's expected types.
@@ -69,15 +113,13 @@ func convertArguments(args []interface{}, functionType reflect.Type) ([]reflect.
 			expectedType = functionType.In(i)
 		}
 
-		argValue := reflect.ValueOf(arg)
-
 		// Handle slices for variadic functions
-		if isVariadic && i >= numIn-1 && argValue.Kind() == reflect.Slice {
+		if argValue := reflect.ValueOf(arg); isVariadic && i >= numIn-1 && argValue.Kind() == reflect.Slice {
 			// Unpack the slice into individual arguments
 			for j := 0; j < argValue.Len(); j++ {
-				elem := argValue.Index(j)
-				if !elem.Type().AssignableTo(expectedType) {
-					return nil, fmt.Errorf("argument %d (element %d): expected %s, got %s", i+1, j+1, expectedType, elem.Type())
+				elem, err := convertValue(argValue.Index(j).Interface(), expectedType)
+				if err != nil {
+					return nil, fmt.Errorf("argument %d (element %d): %v", i+1, j+1, err)
 				}
 				argValues = append(argValues, elem)
 			}
@@ -85,12 +127,9 @@ func convertArguments(args []interface{}, functionType reflect.Type) ([]reflect.
 		}
 
 		// Handle non-slice arguments
-		if !argValue.Type().AssignableTo(expectedType) {
-			if argValue.CanConvert(expectedType) {
-				argValue = argValue.Convert(expectedType)
-			} else {
-				return nil, fmt.Errorf("argument %d: expected %s, got %s", i+1, expectedType, argValue.Type())
-			}
+		argValue, err := convertValue(arg, expectedType)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %v", i+1, err)
 		}
 
 		argValues = append(argValues, argValue)
@@ -99,6 +138,101 @@ func convertArguments(args []interface{}, functionType reflect.Type) ([]reflect.
 	return argValues, nil
 }
 
+// convertValue converts a single argument to the expected type. Numbers may
+// arrive as json.Number when the caller decoded JSON with UseNumber; they are
+// parsed directly into the expected numeric kind. Conversions that would lose
+// information, such as 2.5 to an int, are rejected.
+func convertValue(arg interface{}, expectedType reflect.Type) (reflect.Value, error) {
+	if number, ok := arg.(json.Number); ok {
+		return convertNumber(number, expectedType)
+	}
+
+	if arg == nil {
+		return reflect.Value{}, fmt.Errorf("expected %s, got null", expectedType)
+	}
+
+	argValue := reflect.ValueOf(arg)
+	if argValue.Type().AssignableTo(expectedType) {
+		return argValue, nil
+	}
+
+	sameKind := argValue.Kind() == expectedType.Kind()
+	bothNumeric := isNumeric(argValue.Kind()) && isNumeric(expectedType.Kind())
+	if !argValue.CanConvert(expectedType) || !(sameKind || bothNumeric) {
+		return reflect.Value{}, fmt.Errorf("expected %s, got %s", expectedType, argValue.Type())
+	}
+
+	converted := argValue.Convert(expectedType)
+	if !converted.Convert(argValue.Type()).Equal(argValue) {
+		return reflect.Value{}, fmt.Errorf("expected %s, got %v which cannot be represented exactly", expectedType, arg)
+	}
+
+	return converted, nil
+}
+
+// convertNumber parses a JSON number into the expected numeric type.
+func convertNumber(number json.Number, expectedType reflect.Type) (reflect.Value, error) {
+	value := reflect.New(expectedType).Elem()
+
+	switch kind := expectedType.Kind(); {
+	case kind >= reflect.Int && kind <= reflect.Int64:
+		i, err := strconv.ParseInt(number.String(), 10, expectedType.Bits())
+		if err != nil {
+			f, ok := integralFloat(number)
+			if !ok || f < -math.Ldexp(1, expectedType.Bits()-1) || f >= math.Ldexp(1, expectedType.Bits()-1) {
+				return reflect.Value{}, fmt.Errorf("expected %s, got %s", expectedType, number)
+			}
+			i = int64(f)
+		}
+		value.SetInt(i)
+	case kind >= reflect.Uint && kind <= reflect.Uintptr:
+		u, err := strconv.ParseUint(number.String(), 10, expectedType.Bits())
+		if err != nil {
+			f, ok := integralFloat(number)
+			if !ok || f < 0 || f >= math.Ldexp(1, expectedType.Bits()) {
+				return reflect.Value{}, fmt.Errorf("expected %s, got %s", expectedType, number)
+			}
+			u = uint64(f)
+		}
+		value.SetUint(u)
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		f, err := strconv.ParseFloat(number.String(), expectedType.Bits())
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("expected %s, got %s", expectedType, number)
+		}
+		value.SetFloat(f)
+	case kind == reflect.Interface && reflect.TypeOf(number).AssignableTo(expectedType):
+		if i, err := number.Int64(); err == nil {
+			return reflect.ValueOf(int(i)), nil
+		}
+		f, err := number.Float64()
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("invalid number %s", number)
+		}
+		return reflect.ValueOf(f), nil
+	default:
+		return reflect.Value{}, fmt.Errorf("expected %s, got number %s", expectedType, number)
+	}
+
+	return value, nil
+}
+
+// integralFloat parses a number written as a float, such as 5.0 or 1e2, and
+// reports whether it is finite and integral, so that it can stand for an
+// integer argument.
+func integralFloat(number json.Number) (float64, bool) {
+	f, err := strconv.ParseFloat(number.String(), 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) || f != math.Trunc(f) {
+		return 0, false
+	}
+	return f, true
+}
+
+// isNumeric reports whether a kind is an integer or floating-point kind.
+func isNumeric(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
+
 func callFunction(functionValue reflect.Value, argValues []reflect.Value) (results []reflect.Value, err error) {
 	defer func() {
 		if r := recover(); r != nil {
@@ -0,0 +1,339 @@
+// Package grammar derives decoding constraints from a ToolStore so that an
+// LLM can only produce function calls that name a registered tool and pass
+// arguments of the right number and types.
+//
+// Two equivalent forms are produced: a JSON schema, as accepted by Ollama's
+// structured outputs, and a GBNF grammar, as accepted by the llama.cpp server.
+package grammar
+
+import (
+	"encoding/json"
+	"fmt"
+	"go-agent/tools/evaluation"
+	"go-agent/tools/toolstore"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Grammar holds the constraints for a tool set in both supported forms.
+type Grammar struct {
+	Schema map[string]any `json:"schema"`
+	GBNF   string         `json:"gbnf"`
+}
+
+// SchemaJSON returns the JSON schema encoded as JSON.
+func (g Grammar) SchemaJSON() (json.RawMessage, error) {
+	return json.Marshal(g.Schema)
+}
+
+// valueType is the JSON type accepted for a parameter.
+type valueType struct {
+	kind string // "integer", "number", "string", "boolean", "array" or "any"
+	elem *valueType
+	enum []string
+}
+
+// toolSignature describes the arguments a tool accepts.
+type toolSignature struct {
+	name     string
+	params   []valueType
+	variadic *valueType // Element type of a trailing variadic parameter
+}
+
+// Option configures FromToolStore.
+type Option func(*options)
+
+type options struct {
+	allowAnswer bool
+}
+
+// AllowAnswer also accepts the reply {"function": null, "answer": "..."},
+// with which the LLM declines to call a tool when none applies.
+func AllowAnswer() Option {
+	return func(o *options) {
+		o.allowAnswer = true
+	}
+}
+
+// FromToolStore builds the grammar for all tools in the store.
+func FromToolStore(ts *toolstore.ToolStore, opts ...Option) (Grammar, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	names := ts.ListToolNames()
+	sort.Strings(names)
+
+	signatures := make([]toolSignature, 0, len(names))
+	for _, name := range names {
+		tool, err := ts.GetTool(name)
+		if err != nil {
+			return Grammar{}, err
+		}
+		signature, err := signatureOf(name, tool)
+		if err != nil {
+			return Grammar{}, err
+		}
+		signatures = append(signatures, signature)
+	}
+
+	if len(signatures) == 0 {
+		return Grammar{}, fmt.Errorf("%w: the tool store is empty", toolstore.ErrToolNotFound)
+	}
+
+	return Grammar{
+		Schema: schema(signatures, o.allowAnswer),
+		GBNF:   gbnf(signatures, o.allowAnswer),
+	}, nil
+}
+
+// signatureOf inspects the function of a tool. For a generic tool the
+// instantiations are merged, so that each parameter accepts any type one of
+// the instantiations accepts.
+func signatureOf(name string, tool evaluation.Tool) (toolSignature, error) {
+	functions := []interface{}{tool.Function}
+	if instantiations, ok := tool.Function.(evaluation.Instantiations); ok {
+		functions = functions[:0]
+		for _, typeArgs := range instantiations.TypeArguments() {
+			functions = append(functions, instantiations[typeArgs])
+		}
+	}
+
+	signature := toolSignature{name: name}
+	for i, function := range functions {
+		functionType := reflect.TypeOf(function)
+		if functionType == nil || functionType.Kind() != reflect.Func {
+			return toolSignature{}, fmt.Errorf("%w: %s", evaluation.ErrNotAFunction, name)
+		}
+
+		current := toolSignature{name: name}
+		numIn := functionType.NumIn()
+		for j := 0; j < numIn; j++ {
+			if functionType.IsVariadic() && j == numIn-1 {
+				elem := typeOf(functionType.In(j).Elem())
+				current.variadic = &elem
+				continue
+			}
+			current.params = append(current.params, typeOf(functionType.In(j)))
+		}
+
+		if i == 0 {
+			signature = current
+			continue
+		}
+		signature = mergeSignatures(signature, current)
+	}
+
+	for i, param := range tool.Metadata.Params {
+		if i < len(signature.params) && signature.params[i].kind == "string" {
+			signature.params[i].enum = param.Enum
+		}
+	}
+
+	return signature, nil
+}
+
+// typeOf maps a Go type to the JSON type used to encode it.
+func typeOf(t reflect.Type) valueType {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return valueType{kind: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return valueType{kind: "number"}
+	case reflect.String:
+		return valueType{kind: "string"}
+	case reflect.Bool:
+		return valueType{kind: "boolean"}
+	case reflect.Slice, reflect.Array:
+		elem := typeOf(t.Elem())
+		return valueType{kind: "array", elem: &elem}
+	default:
+		return valueType{kind: "any"}
+	}
+}
+
+// mergeSignatures widens two signatures of the same arity into one.
+func mergeSignatures(a, b toolSignature) toolSignature {
+	if len(a.params) != len(b.params) || (a.variadic == nil) != (b.variadic == nil) {
+		return toolSignature{name: a.name, variadic: &valueType{kind: "any"}}
+	}
+
+	merged := toolSignature{name: a.name}
+	for i := range a.params {
+		merged.params = append(merged.params, widen(a.params[i], b.params[i]))
+	}
+	if a.variadic != nil {
+		v := widen(*a.variadic, *b.variadic)
+		merged.variadic = &v
+	}
+	return merged
+}
+
+func widen(a, b valueType) valueType {
+	switch {
+	case a.kind == b.kind && a.kind != "array":
+		return a
+	case (a.kind == "integer" || a.kind == "number") && (b.kind == "integer" || b.kind == "number"):
+		return valueType{kind: "number"}
+	default:
+		return valueType{kind: "any"}
+	}
+}
+
+// schema builds a JSON schema accepting exactly one call of any tool, or
+// optionally an answer without a call.
+func schema(signatures []toolSignature, allowAnswer bool) map[string]any {
+	calls := make([]any, 0, len(signatures))
+	for _, signature := range signatures {
+		arguments := map[string]any{
+			"type":     "array",
+			"minItems": len(signature.params),
+		}
+
+		prefixItems := make([]any, len(signature.params))
+		for i, param := range signature.params {
+			prefixItems[i] = param.schema()
+		}
+		if len(prefixItems) > 0 {
+			arguments["prefixItems"] = prefixItems
+		}
+
+		if signature.variadic != nil {
+			arguments["items"] = signature.variadic.schema()
+		} else {
+			arguments["items"] = false
+			arguments["maxItems"] = len(signature.params)
+		}
+
+		calls = append(calls, map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"function":  map[string]any{"type": "string", "const": signature.name},
+				"arguments": arguments,
+			},
+			"required":             []string{"function", "arguments"},
+			"additionalProperties": false,
+		})
+	}
+
+	if allowAnswer {
+		calls = append(calls, map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"function": map[string]any{"type": "null"},
+				"answer":   map[string]any{"type": "string"},
+			},
+			"required":             []string{"function", "answer"},
+			"additionalProperties": false,
+		})
+	}
+
+	return map[string]any{"anyOf": calls}
+}
+
+func (v valueType) schema() map[string]any {
+	switch v.kind {
+	case "any":
+		return map[string]any{}
+	case "array":
+		return map[string]any{"type": "array", "items": v.elem.schema()}
+	}
+
+	s := map[string]any{"type": v.kind}
+	if len(v.enum) > 0 {
+		s["enum"] = v.enum
+	}
+	return s
+}
+
+// gbnfRules are the shared rules referenced by the per-tool rules.
+const gbnfRules = `ws ::= [ \t\n]{0,20}
+integer ::= "-"? ([0-9] | [1-9] [0-9]{0,15})
+number ::= "-"? ([0-9] | [1-9] [0-9]{0,15}) ("." [0-9]+)? ([eE] [-+]? [0-9]+)?
+string ::= "\"" ([^"\\\x7F\x00-\x1F] | "\\" (["\\/bfnrt] | "u" [0-9a-fA-F]{4}))* "\""
+boolean ::= "true" | "false"
+value ::= object | array | string | number | boolean | "null"
+object ::= "{" ws (string ws ":" ws value (ws "," ws string ws ":" ws value)*)? ws "}"
+array ::= "[" ws (value (ws "," ws value)*)? ws "]"
+`
+
+var ruleNameRegex = regexp.MustCompile(`[^a-zA-Z0-9-]+`)
+
+// gbnf builds a GBNF grammar accepting exactly one call of any tool, or
+// optionally an answer without a call.
+func gbnf(signatures []toolSignature, allowAnswer bool) string {
+	var grammar strings.Builder
+
+	alternatives := make([]string, len(signatures))
+	for i, signature := range signatures {
+		alternatives[i] = ruleName(signature.name)
+	}
+	if allowAnswer {
+		alternatives = append(alternatives, "no-call")
+	}
+	grammar.WriteString(fmt.Sprintf("root ::= ws \"{\" ws (%s) ws \"}\" ws\n", strings.Join(alternatives, " | ")))
+
+	for _, signature := range signatures {
+		name, _ := json.Marshal(signature.name)
+
+		var args []string
+		for _, param := range signature.params {
+			args = append(args, param.gbnf())
+		}
+		list := strings.Join(args, ` ws "," ws `)
+
+		if signature.variadic != nil {
+			element := signature.variadic.gbnf()
+			if list == "" {
+				list = fmt.Sprintf(`(%s (ws "," ws %s)*)?`, element, element)
+			} else {
+				list = fmt.Sprintf(`%s (ws "," ws %s)*`, list, element)
+			}
+		}
+
+		grammar.WriteString(fmt.Sprintf(
+			"%s ::= \"\\\"function\\\"\" ws \":\" ws %s ws \",\" ws \"\\\"arguments\\\"\" ws \":\" ws \"[\" ws %s ws \"]\"\n",
+			ruleName(signature.name), quoteLiteral(string(name)), list))
+	}
+
+	if allowAnswer {
+		grammar.WriteString("no-call ::= \"\\\"function\\\"\" ws \":\" ws \"null\" ws \",\" ws \"\\\"answer\\\"\" ws \":\" ws string\n")
+	}
+
+	grammar.WriteString(gbnfRules)
+	return grammar.String()
+}
+
+func (v valueType) gbnf() string {
+	switch v.kind {
+	case "any":
+		return "value"
+	case "array":
+		elem := v.elem.gbnf()
+		return fmt.Sprintf(`"[" ws (%s (ws "," ws %s)*)? ws "]"`, elem, elem)
+	case "string":
+		if len(v.enum) > 0 {
+			options := make([]string, len(v.enum))
+			for i, option := range v.enum {
+				encoded, _ := json.Marshal(option)
+				options[i] = quoteLiteral(string(encoded))
+			}
+			return "(" + strings.Join(options, " | ") + ")"
+		}
+	}
+	return v.kind
+}
+
+// ruleName turns a tool name into a valid GBNF rule name.
+func ruleName(tool string) string {
+	return "call-" + ruleNameRegex.ReplaceAllString(tool, "-")
+}
+
+// quoteLiteral quotes text as a GBNF string literal.
+func quoteLiteral(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+}
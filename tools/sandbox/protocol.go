@@ -0,0 +1,140 @@
+package sandbox
+
+import (
+	"encoding/json"
+	"errors"
+	"go-agent/metadata"
+	"go-agent/tools/evaluation"
+	"go-agent/tools/toolstore"
+	"math"
+	"strconv"
+)
+
+// The worker speaks JSON-RPC 2.0 on stdin and stdout, one message per line.
+// The only method is "evaluate".
+const (
+	jsonrpcVersion = "2.0"
+	methodEvaluate = "evaluate"
+)
+
+type rpcRequest struct {
+	JSONRPC string         `json:"jsonrpc"`
+	ID      uint64         `json:"id"`
+	Method  string         `json:"method"`
+	Params  evaluateParams `json:"params"`
+}
+
+type evaluateParams struct {
+	Tool      string                `json:"tool"`
+	Arguments []interface{}         `json:"arguments"`
+	Returns   []metadata.ReturnType `json:"returns,omitempty"` // Used to name the results
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Result  *evaluateResult `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// evaluateResult carries the values returned by the tool and the error, if
+// any. GoTypes holds the dynamic type of each value, so that the caller can
+// restore values that JSON does not distinguish, such as int and float64.
+type evaluateResult struct {
+	Values  []evaluation.NamedValue `json:"values"`
+	GoTypes []string                `json:"go_types"`
+	Error   *rpcError               `json:"error,omitempty"`
+}
+
+// rpcError is a protocol error or an error returned by the tool. Class names
+// the sentinel error to restore on the caller's side, if any.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Class   string `json:"class,omitempty"`
+}
+
+// JSON-RPC error codes.
+const (
+	codeParseError     = -32700
+	codeMethodNotFound = -32601
+	codeToolError      = -32000
+)
+
+// sentinels are the errors that keep their identity across the process boundary.
+var sentinels = map[string]error{
+	"tool_not_found":    toolstore.ErrToolNotFound,
+	"not_a_function":    evaluation.ErrNotAFunction,
+	"argument_mismatch": evaluation.ErrArgumentMismatch,
+	"argument_type":     evaluation.ErrArgumentType,
+	"function_panic":    evaluation.ErrFunctionPanic,
+	"no_instantiation":  evaluation.ErrNoInstantiation,
+}
+
+func newRPCError(code int, err error) *rpcError {
+	e := &rpcError{Code: code, Message: err.Error()}
+	for class, sentinel := range sentinels {
+		if errors.Is(err, sentinel) {
+			e.Class = class
+			break
+		}
+	}
+	return e
+}
+
+// remoteError is an error that occurred in the worker. It unwraps to the
+// sentinel named by its class, so that errors.Is works as for in-process tools.
+type remoteError struct {
+	message  string
+	sentinel error
+}
+
+func (e *remoteError) Error() string { return e.message }
+func (e *remoteError) Unwrap() error { return e.sentinel }
+
+func (e *rpcError) err() error {
+	if e == nil {
+		return nil
+	}
+	return &remoteError{message: e.Message, sentinel: sentinels[e.Class]}
+}
+
+// restore converts a decoded JSON number, or the string form of a non-finite
+// float, back to the Go type the tool returned.
+func restore(value any, goType string) any {
+	if s, ok := value.(string); ok && (goType == "float64" || goType == "float32") {
+		// Non-finite floats are sent as "+Inf", "-Inf" or "NaN".
+		if f, err := strconv.ParseFloat(s, 64); err == nil && (math.IsInf(f, 0) || math.IsNaN(f)) {
+			if goType == "float32" {
+				return float32(f)
+			}
+			return f
+		}
+		return value
+	}
+
+	number, ok := value.(json.Number)
+	if !ok {
+		return value
+	}
+
+	switch goType {
+	case "int":
+		if i, err := number.Int64(); err == nil {
+			return int(i)
+		}
+	case "int64":
+		if i, err := number.Int64(); err == nil {
+			return i
+		}
+	case "float64":
+		if f, err := number.Float64(); err == nil {
+			return f
+		}
+	case "float32":
+		if f, err := number.Float64(); err == nil {
+			return float32(f)
+		}
+	}
+	return value
+}
@@ -0,0 +1,16 @@
+//go:build linux
+
+package sandbox
+
+import (
+	"runtime/debug"
+	"syscall"
+)
+
+// setMemoryLimit caps the address space of the worker. The Go runtime gets a
+// slightly lower soft limit so that it collects garbage harder before the hard
+// limit makes allocations fail.
+func setMemoryLimit(limit uint64) error {
+	debug.SetMemoryLimit(int64(limit) / 10 * 9)
+	return syscall.Setrlimit(syscall.RLIMIT_AS, &syscall.Rlimit{Cur: limit, Max: limit})
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package sandbox
+
+import "runtime/debug"
+
+// setMemoryLimit only sets a soft limit where rlimits are not supported: the
+// garbage collector works harder near it, but the worker is not stopped.
+func setMemoryLimit(limit uint64) error {
+	debug.SetMemoryLimit(int64(limit))
+	return nil
+}
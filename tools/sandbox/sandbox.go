@@ -0,0 +1,329 @@
+// Package sandbox runs tools in a worker subprocess, so that a tool that loops
+// forever, exhausts memory or exits the process cannot take the agent down.
+//
+// The worker is a Go program holding the same function registry as the agent;
+// by default the agent's own executable is started again in worker mode (see
+// IsWorker). The agent and the worker exchange JSON-RPC messages over stdio.
+// A call that exceeds its time limit kills the worker, and a worker that dies
+// is replaced on the next call.
+package sandbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-agent/tools/evaluation"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTimeout       error = &classError{"tool call timed out", "timeout"}
+	ErrWorkerCrashed error = &classError{"sandbox worker crashed", "worker_crashed"}
+	ErrMemoryLimit   error = &classError{"tool exceeded the memory limit", "memory_limit"}
+)
+
+// classError is a sentinel error that names its class, as reported by
+// agent.ErrorClass, so that the agent need not know about the sandbox.
+type classError struct {
+	message string
+	class   string
+}
+
+func (e *classError) Error() string      { return e.message }
+func (e *classError) ErrorClass() string { return e.class }
+
+// Config configures a Sandbox.
+type Config struct {
+	Command     []string      // Worker command line; the current executable when empty
+	Timeout     time.Duration // Wall-clock limit per call; none when zero
+	MemoryLimit uint64        // Address space limit of the worker in bytes; none when zero
+	Stderr      io.Writer     // Receives the worker's stderr, including tool output; os.Stderr when nil
+	Logger      *slog.Logger
+}
+
+// Sandbox is an evaluation.Executor running tools in a worker subprocess.
+// Calls are serialized: the worker runs one tool at a time.
+type Sandbox struct {
+	config Config
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	worker *worker
+	nextID uint64
+}
+
+// New creates a Sandbox. The worker is started on the first call.
+func New(config Config) *Sandbox {
+	logger := config.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if config.Stderr == nil {
+		config.Stderr = os.Stderr
+	}
+	return &Sandbox{config: config, logger: logger}
+}
+
+// Execute runs the tool in the worker. The worker looks the tool up by name in
+// its own registry; the tool's metadata is only used to name the results.
+func (s *Sandbox) Execute(ctx context.Context, name string, tool evaluation.Tool, args []interface{}) (evaluation.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.worker == nil {
+		w, err := s.start()
+		if err != nil {
+			return evaluation.Result{}, err
+		}
+		s.worker = w
+	}
+
+	if s.config.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.config.Timeout)
+		defer cancel()
+	}
+
+	s.nextID++
+	request := rpcRequest{
+		JSONRPC: jsonrpcVersion,
+		ID:      s.nextID,
+		Method:  methodEvaluate,
+		Params:  evaluateParams{Tool: name, Arguments: args, Returns: tool.Metadata.Return},
+	}
+	line, err := json.Marshal(request)
+	if err != nil {
+		return evaluation.Result{}, fmt.Errorf("error encoding tool call: %w", err)
+	}
+
+	s.worker.stderr.reset()
+	if _, err := s.worker.stdin.Write(append(line, '\n')); err != nil {
+		return evaluation.Result{}, s.crashed(name)
+	}
+
+	for {
+		select {
+		case response, ok := <-s.worker.responses:
+			if !ok {
+				return evaluation.Result{}, s.crashed(name)
+			}
+			if response.ID != request.ID {
+				continue // Late reply to an earlier request
+			}
+			return decodeResponse(response)
+
+		case <-ctx.Done():
+			s.logger.Warn("Killing sandbox worker", "tool", name, "error", ctx.Err())
+			s.stop()
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return evaluation.Result{}, fmt.Errorf("%w: %s: %w", ErrTimeout, name, ctx.Err())
+			}
+			return evaluation.Result{}, ctx.Err()
+		}
+	}
+}
+
+// Close stops the worker.
+func (s *Sandbox) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.worker == nil {
+		return nil
+	}
+
+	s.worker.stdin.Close()
+	select {
+	case <-s.worker.exited:
+	case <-time.After(time.Second):
+		s.worker.cmd.Process.Kill()
+		<-s.worker.exited
+	}
+	s.worker = nil
+	return nil
+}
+
+// worker is a running worker process.
+type worker struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	responses chan rpcResponse // Closed when the worker's stdout is closed
+	exited    chan struct{}    // Closed once the process has been waited for
+	exitErr   error
+	stderr    *tail
+}
+
+func (s *Sandbox) start() (*worker, error) {
+	command := s.config.Command
+	if len(command) == 0 {
+		executable, err := os.Executable()
+		if err != nil {
+			return nil, fmt.Errorf("error locating the worker executable: %w", err)
+		}
+		command = []string{executable}
+	}
+
+	w := &worker{
+		cmd:       exec.Command(command[0], command[1:]...),
+		responses: make(chan rpcResponse),
+		exited:    make(chan struct{}),
+		stderr:    &tail{},
+	}
+	w.cmd.Env = append(os.Environ(), envWorker+"=1")
+	if s.config.MemoryLimit > 0 {
+		w.cmd.Env = append(w.cmd.Env, fmt.Sprintf("%s=%d", envMemoryLimit, s.config.MemoryLimit))
+	}
+	w.cmd.Stderr = io.MultiWriter(s.config.Stderr, w.stderr)
+
+	stdin, err := w.cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := w.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	w.stdin = stdin
+
+	if err := w.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting the sandbox worker: %w", err)
+	}
+	s.logger.Debug("Started sandbox worker", "pid", w.cmd.Process.Pid)
+
+	go w.read(stdout)
+	return w, nil
+}
+
+// read forwards the worker's responses until its stdout is closed, then waits
+// for the process to exit.
+func (w *worker) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var response rpcResponse
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.UseNumber()
+		if err := decoder.Decode(&response); err != nil {
+			continue
+		}
+		w.responses <- response
+	}
+	close(w.responses)
+
+	w.exitErr = w.cmd.Wait()
+	close(w.exited)
+}
+
+// stop kills the worker and forgets it, so that the next call starts another.
+func (s *Sandbox) stop() {
+	s.worker.cmd.Process.Kill()
+	go func(w *worker) {
+		for range w.responses {
+		}
+	}(s.worker)
+	<-s.worker.exited
+	s.worker = nil
+}
+
+// crashed reports the death of the worker during a call and forgets it, so
+// that the next call starts another.
+func (s *Sandbox) crashed(tool string) error {
+	w := s.worker
+	s.worker = nil
+	go func() {
+		for range w.responses {
+		}
+	}()
+	<-w.exited
+
+	output := w.stderr.summary()
+	s.logger.Error("Sandbox worker crashed", "tool", tool, "exit", w.exitErr, "output", output)
+
+	sentinel := ErrWorkerCrashed
+	if strings.Contains(output, "out of memory") {
+		sentinel = ErrMemoryLimit
+	}
+	if output != "" {
+		return fmt.Errorf("%w: %s: %v: %s", sentinel, tool, w.exitErr, output)
+	}
+	return fmt.Errorf("%w: %s: %v", sentinel, tool, w.exitErr)
+}
+
+func decodeResponse(response rpcResponse) (evaluation.Result, error) {
+	if response.Error != nil {
+		return evaluation.Result{}, response.Error.err()
+	}
+	if response.Result == nil {
+		return evaluation.Result{}, fmt.Errorf("%w: empty response", ErrWorkerCrashed)
+	}
+
+	result := evaluation.Result{Values: response.Result.Values}
+	for i := range result.Values {
+		if i < len(response.Result.GoTypes) {
+			result.Values[i].Value = restore(result.Values[i].Value, response.Result.GoTypes[i])
+		}
+	}
+	return result, response.Result.Error.err()
+}
+
+// tail watches the worker's stderr for the line explaining a crash: the
+// message of a Go panic or fatal error, or else the last line written.
+type tail struct {
+	mu       sync.Mutex
+	partial  []byte
+	last     string
+	headline string
+}
+
+func (t *tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.partial = append(t.partial, p...)
+	for {
+		i := bytes.IndexByte(t.partial, '\n')
+		if i < 0 {
+			break
+		}
+		t.line(strings.TrimSpace(string(t.partial[:i])))
+		t.partial = t.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (t *tail) line(line string) {
+	if line == "" {
+		return
+	}
+	t.last = line
+	if t.headline == "" && (strings.HasPrefix(line, "panic: ") || strings.HasPrefix(line, "fatal error: ")) {
+		t.headline = line
+	}
+}
+
+// reset forgets the output seen so far.
+func (t *tail) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.partial, t.last, t.headline = nil, "", ""
+}
+
+func (t *tail) summary() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.line(strings.TrimSpace(string(t.partial)))
+	if t.headline != "" {
+		return t.headline
+	}
+	return t.last
+}
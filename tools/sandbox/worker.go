@@ -0,0 +1,119 @@
+package sandbox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-agent/tools/evaluation"
+	"go-agent/tools/toolstore"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+)
+
+// Environment variables through which the sandbox configures its workers.
+const (
+	envWorker      = "GO_AGENT_SANDBOX_WORKER"
+	envMemoryLimit = "GO_AGENT_SANDBOX_MEMORY_LIMIT"
+)
+
+// IsWorker reports whether the process was started as a sandbox worker. A
+// program that uses a Sandbox with its own executable as the worker must check
+// it first thing in main and hand over to Serve:
+//
+//	if sandbox.IsWorker() {
+//		sandbox.Serve(calculator.FunctionRegistry())
+//	}
+func IsWorker() bool {
+	return os.Getenv(envWorker) == "1"
+}
+
+// Serve runs the worker loop on stdin and stdout with the given functions and
+// exits the process when stdin is closed. Anything the tools print to stdout
+// is redirected to stderr so that it cannot corrupt the protocol.
+func Serve(registry map[string]interface{}) {
+	out := os.Stdout
+	os.Stdout = os.Stderr
+
+	if limit, err := strconv.ParseUint(os.Getenv(envMemoryLimit), 10, 64); err == nil && limit > 0 {
+		if err := setMemoryLimit(limit); err != nil {
+			slog.Warn("Failed to limit worker memory", "limit", limit, "error", err)
+		}
+	}
+
+	store := toolstore.NewToolStore(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+	for name, function := range registry {
+		store.AddTool(name, evaluation.Tool{Function: function})
+	}
+
+	if err := serve(store, os.Stdin, out); err != nil {
+		fmt.Fprintf(os.Stderr, "sandbox worker: %v\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// serve answers requests until in is exhausted.
+func serve(store *toolstore.ToolStore, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	encoder := json.NewEncoder(out)
+
+	for scanner.Scan() {
+		var request rpcRequest
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.UseNumber()
+
+		response := rpcResponse{JSONRPC: jsonrpcVersion}
+		switch err := decoder.Decode(&request); {
+		case err != nil:
+			response.Error = newRPCError(codeParseError, err)
+		case request.Method != methodEvaluate:
+			response.ID = request.ID
+			response.Error = newRPCError(codeMethodNotFound, fmt.Errorf("unknown method %q", request.Method))
+		default:
+			response.ID = request.ID
+			response.Result = evaluate(store, request.Params)
+		}
+
+		if err := encoder.Encode(response); err != nil {
+			// The result could not be encoded, e.g. a value of an unsupported
+			// type; report it as a failure of the call rather than exiting.
+			failed := rpcResponse{JSONRPC: jsonrpcVersion, ID: response.ID, Result: &evaluateResult{
+				Values: []evaluation.NamedValue{},
+				Error:  newRPCError(codeToolError, fmt.Errorf("error encoding result: %w", err)),
+			}}
+			if err := encoder.Encode(failed); err != nil {
+				return err
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// evaluate runs one tool call. Errors are reported in the result, next to
+// whatever values the tool returned.
+func evaluate(store *toolstore.ToolStore, params evaluateParams) *evaluateResult {
+	result := &evaluateResult{Values: []evaluation.NamedValue{}}
+
+	tool, err := store.GetTool(params.Tool)
+	if err != nil {
+		result.Error = newRPCError(codeToolError, err)
+		return result
+	}
+	tool.Metadata.Return = params.Returns
+
+	values, err := tool.Evaluate(params.Arguments)
+	if err != nil {
+		result.Error = newRPCError(codeToolError, err)
+	}
+
+	result.Values = values.Values
+	result.GoTypes = make([]string, len(values.Values))
+	for i, value := range values.Values {
+		result.GoTypes[i] = fmt.Sprintf("%T", value.Value)
+	}
+	return result
+}
@@ -0,0 +1,302 @@
+package toolstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-agent/tools/evaluation"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	ErrToolTimeout      = errors.New("tool call timed out")
+	ErrConcurrencyLimit = errors.New("too many concurrent calls")
+	ErrRateLimited      = errors.New("rate limit exceeded")
+	ErrCircuitOpen      = errors.New("circuit breaker is open")
+)
+
+// ExecutionPolicy limits how a tool may be run. Zero fields impose no limit.
+type ExecutionPolicy struct {
+	Timeout       time.Duration // Wall-clock limit per call
+	MaxConcurrent int           // Calls allowed to run at the same time; further calls are rejected
+	RateLimit     float64       // Calls allowed per second on average
+	Burst         int           // Calls allowed at once under the rate limit; 1 when zero
+
+	// The circuit breaker opens after BreakerThreshold consecutive failures
+	// and rejects calls for BreakerCooldown. After the cooldown one trial call
+	// is let through: it closes the breaker if it succeeds, and opens it again
+	// otherwise.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	// Failure decides which errors count against the circuit breaker;
+	// IsFailure when nil. Errors returned by the tool itself, such as
+	// "division by zero", are usually not failures of the tool.
+	Failure func(error) bool
+}
+
+// LimitError is returned when a call is stopped or rejected by the execution
+// policy of its tool. It unwraps to ErrToolTimeout, ErrConcurrencyLimit,
+// ErrRateLimited or ErrCircuitOpen.
+type LimitError struct {
+	Tool       string
+	Err        error
+	RetryAfter time.Duration // When the call may succeed if tried again, if known
+}
+
+func (e *LimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("%v: %s (retry after %v)", e.Err, e.Tool, e.RetryAfter.Round(time.Millisecond))
+	}
+	return fmt.Sprintf("%v: %s", e.Err, e.Tool)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// IsFailure reports whether the error shows the tool malfunctioning: a panic
+// or a timeout.
+func IsFailure(err error) bool {
+	return errors.Is(err, evaluation.ErrFunctionPanic) ||
+		errors.Is(err, ErrToolTimeout) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// SetPolicy sets the execution policy of a tool, replacing the default policy
+// for it. The limits apply to calls made through the Executor of the store.
+func (ts *ToolStore) SetPolicy(name string, policy ExecutionPolicy) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.policies == nil {
+		ts.policies = make(map[string]ExecutionPolicy)
+	}
+	ts.policies[name] = policy
+	delete(ts.guards, name)
+}
+
+// SetDefaultPolicy sets the execution policy of the tools without their own.
+func (ts *ToolStore) SetDefaultPolicy(policy ExecutionPolicy) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.defaultPolicy = policy
+	for name := range ts.guards {
+		if _, ok := ts.policies[name]; !ok {
+			delete(ts.guards, name)
+		}
+	}
+}
+
+// Policy returns the execution policy that applies to a tool.
+func (ts *ToolStore) Policy(name string) ExecutionPolicy {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	baseName, _, _ := splitTypeArgs(name)
+	if policy, ok := ts.policies[baseName]; ok {
+		return policy
+	}
+	return ts.defaultPolicy
+}
+
+// Executor wraps next, which runs the tools, so that each call observes the
+// execution policy of its tool. The limits are shared by all executors of the
+// store. When the store has a cache, calls of pure tools answered from it do
+// not count against the limits.
+func (ts *ToolStore) Executor(next evaluation.Executor) evaluation.Executor {
+	var executor evaluation.Executor = policyExecutor{store: ts, next: next}
+	if cache := ts.Cache(); cache != nil {
+		executor = cache.Executor(executor)
+	}
+	return executor
+}
+
+type policyExecutor struct {
+	store *ToolStore
+	next  evaluation.Executor
+}
+
+func (e policyExecutor) Execute(ctx context.Context, name string, tool evaluation.Tool, args []interface{}) (evaluation.Result, error) {
+	g := e.store.guard(name)
+	if err := g.acquire(name); err != nil {
+		return evaluation.Result{}, err
+	}
+
+	return g.run(ctx, name, func(ctx context.Context) (evaluation.Result, error) {
+		return e.next.Execute(ctx, name, tool, args)
+	})
+}
+
+// guard returns the state of the limits of a tool. Instantiations of a
+// generic tool share the limits of the tool.
+func (ts *ToolStore) guard(name string) *guard {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	baseName, _, _ := splitTypeArgs(name)
+	if g, ok := ts.guards[baseName]; ok {
+		return g
+	}
+
+	policy, ok := ts.policies[baseName]
+	if !ok {
+		policy = ts.defaultPolicy
+	}
+	if policy.Failure == nil {
+		policy.Failure = IsFailure
+	}
+
+	g := &guard{policy: policy, tokens: float64(max(policy.Burst, 1)), refilled: time.Now()}
+	if ts.guards == nil {
+		ts.guards = make(map[string]*guard)
+	}
+	ts.guards[baseName] = g
+	return g
+}
+
+// guard enforces the execution policy of one tool.
+type guard struct {
+	policy ExecutionPolicy
+
+	mu       sync.Mutex
+	running  int
+	tokens   float64 // Token bucket of the rate limit
+	refilled time.Time
+	failures int       // Consecutive failures
+	openedAt time.Time // When the breaker opened; zero when closed
+	trial    bool      // A trial call is running while the breaker is half-open
+}
+
+// acquire admits a call or returns the LimitError rejecting it. An admitted
+// call must be followed by release.
+func (g *guard) acquire(tool string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	p := g.policy
+
+	if !g.openedAt.IsZero() {
+		if wait := g.openedAt.Add(p.BreakerCooldown).Sub(now); wait > 0 || g.trial {
+			return &LimitError{Tool: tool, Err: ErrCircuitOpen, RetryAfter: max(wait, 0)}
+		}
+		g.trial = true
+	}
+
+	if p.MaxConcurrent > 0 && g.running >= p.MaxConcurrent {
+		g.trial = false
+		return &LimitError{Tool: tool, Err: ErrConcurrencyLimit}
+	}
+
+	if p.RateLimit > 0 {
+		burst := float64(max(p.Burst, 1))
+		g.tokens = math.Min(burst, g.tokens+now.Sub(g.refilled).Seconds()*p.RateLimit)
+		g.refilled = now
+		if g.tokens < 1 {
+			g.trial = false
+			wait := time.Duration((1 - g.tokens) / p.RateLimit * float64(time.Second))
+			return &LimitError{Tool: tool, Err: ErrRateLimited, RetryAfter: wait}
+		}
+		g.tokens--
+	}
+
+	g.running++
+	return nil
+}
+
+// release records the outcome of an admitted call that has returned.
+func (g *guard) release(err error) {
+	g.record(err)
+	g.finish()
+}
+
+// record updates the breaker with the outcome of an admitted call. A canceled
+// call says nothing about the tool, so it only ends a trial.
+func (g *guard) record(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.policy.BreakerThreshold <= 0 {
+		return
+	}
+
+	if errors.Is(err, context.Canceled) {
+		g.trial = false
+		return
+	}
+	if err != nil && g.policy.Failure(err) {
+		g.failures++
+		if g.trial || g.failures >= g.policy.BreakerThreshold {
+			g.openedAt = time.Now()
+		}
+	} else {
+		g.failures = 0
+		g.openedAt = time.Time{}
+	}
+	g.trial = false
+}
+
+// finish ends an admitted call, which stops counting against MaxConcurrent.
+func (g *guard) finish() {
+	g.mu.Lock()
+	g.running--
+	g.mu.Unlock()
+}
+
+// finishWhenDone ends an abandoned call once it returns, which may be never.
+// Without a concurrency limit it need not be waited for.
+func (g *guard) finishWhenDone(done <-chan outcome) {
+	if g.policy.MaxConcurrent <= 0 {
+		g.finish()
+		return
+	}
+	go func() {
+		<-done
+		g.finish()
+	}()
+}
+
+// outcome is what a call running in the background returned.
+type outcome struct {
+	result evaluation.Result
+	err    error
+}
+
+// run calls the tool under the timeout of the policy. A tool running in
+// process cannot be stopped: after a timeout it keeps running in the
+// background, and keeps counting against MaxConcurrent, until it returns. The
+// timeout counts as a failure for the breaker right away.
+func (g *guard) run(ctx context.Context, tool string, call func(context.Context) (evaluation.Result, error)) (evaluation.Result, error) {
+	if g.policy.Timeout <= 0 {
+		result, err := call(ctx)
+		g.release(err)
+		return result, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, g.policy.Timeout)
+	defer cancel()
+
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := call(ctx)
+		done <- outcome{result, err}
+	}()
+
+	select {
+	case o := <-done:
+		g.release(o.err)
+		return o.result, o.err
+	case <-ctx.Done():
+		err := ctx.Err()
+		if errors.Is(err, context.DeadlineExceeded) {
+			err = &LimitError{Tool: tool, Err: ErrToolTimeout}
+		}
+		g.record(err)
+		g.finishWhenDone(done)
+		return evaluation.Result{}, err
+	}
+}
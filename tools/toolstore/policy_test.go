@@ -0,0 +1,196 @@
+package toolstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-agent/tools/evaluation"
+	"testing"
+	"time"
+)
+
+func newGuard(policy ExecutionPolicy) *guard {
+	if policy.Failure == nil {
+		policy.Failure = IsFailure
+	}
+	return &guard{policy: policy, tokens: float64(max(policy.Burst, 1)), refilled: time.Now()}
+}
+
+func TestGuardAcquire(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy ExecutionPolicy
+		calls  int   // Calls admitted before the last one, none released
+		want   error // Outcome of the last call
+	}{
+		{"no limits", ExecutionPolicy{}, 100, nil},
+		{"below concurrency limit", ExecutionPolicy{MaxConcurrent: 2}, 1, nil},
+		{"at concurrency limit", ExecutionPolicy{MaxConcurrent: 2}, 2, ErrConcurrencyLimit},
+		{"within burst", ExecutionPolicy{RateLimit: 0.001, Burst: 3}, 2, nil},
+		{"burst exhausted", ExecutionPolicy{RateLimit: 0.001, Burst: 3}, 3, ErrRateLimited},
+		{"default burst of one", ExecutionPolicy{RateLimit: 0.001}, 1, ErrRateLimited},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newGuard(tt.policy)
+			for i := range tt.calls {
+				if err := g.acquire("Add"); err != nil {
+					t.Fatalf("call %d: %v", i+1, err)
+				}
+			}
+
+			err := g.acquire("Add")
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Fatalf("acquire = %v, want %v", err, tt.want)
+			}
+			if errors.Is(err, ErrRateLimited) {
+				var limitErr *LimitError
+				if !errors.As(err, &limitErr) || limitErr.RetryAfter <= 0 {
+					t.Errorf("rate limit error %v has no RetryAfter", err)
+				}
+			}
+		})
+	}
+}
+
+func TestGuardReleaseFreesConcurrency(t *testing.T) {
+	g := newGuard(ExecutionPolicy{MaxConcurrent: 1})
+	if err := g.acquire("Add"); err != nil {
+		t.Fatal(err)
+	}
+	g.release(nil)
+	if err := g.acquire("Add"); err != nil {
+		t.Errorf("acquire after release = %v, want nil", err)
+	}
+}
+
+func TestGuardRateRefills(t *testing.T) {
+	g := newGuard(ExecutionPolicy{RateLimit: 1000})
+	if err := g.acquire("Add"); err != nil {
+		t.Fatal(err)
+	}
+	g.release(nil)
+	g.refilled = g.refilled.Add(-time.Second)
+	if err := g.acquire("Add"); err != nil {
+		t.Errorf("acquire after refill = %v, want nil", err)
+	}
+}
+
+func TestGuardBreaker(t *testing.T) {
+	panicked := fmt.Errorf("%w: boom", evaluation.ErrFunctionPanic)
+	toolErr := errors.New("division by zero")
+
+	// Each step is a call: the error its acquire should return and, when
+	// admitted, the error the call returns. "cooldown" ends the cooldown
+	// before the step.
+	type step struct {
+		cooldown bool
+		rejected error
+		result   error
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"opens at threshold", []step{
+			{result: panicked},
+			{result: panicked},
+			{rejected: ErrCircuitOpen},
+		}},
+		{"below threshold", []step{
+			{result: panicked},
+			{result: nil},
+			{result: panicked},
+			{result: nil},
+		}},
+		{"tool errors are not failures", []step{
+			{result: toolErr},
+			{result: toolErr},
+			{result: toolErr},
+		}},
+		{"successful trial closes", []step{
+			{result: panicked},
+			{result: panicked},
+			{cooldown: true, result: nil},
+			{result: panicked},
+			{result: nil},
+		}},
+		{"failed trial reopens", []step{
+			{result: panicked},
+			{result: panicked},
+			{cooldown: true, result: panicked},
+			{rejected: ErrCircuitOpen},
+		}},
+		{"canceled trial only ends the trial", []step{
+			{result: panicked},
+			{result: panicked},
+			{cooldown: true, result: context.Canceled},
+			{result: nil},
+			{result: panicked},
+			{result: nil},
+		}},
+		{"timeouts are failures", []step{
+			{result: &LimitError{Tool: "Add", Err: ErrToolTimeout}},
+			{result: context.DeadlineExceeded},
+			{rejected: ErrCircuitOpen},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newGuard(ExecutionPolicy{BreakerThreshold: 2, BreakerCooldown: time.Hour})
+			for i, s := range tt.steps {
+				if s.cooldown {
+					g.openedAt = g.openedAt.Add(-time.Hour)
+				}
+				err := g.acquire("Add")
+				if s.rejected != nil {
+					if !errors.Is(err, s.rejected) {
+						t.Fatalf("step %d: acquire = %v, want %v", i+1, err, s.rejected)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("step %d: acquire = %v, want nil", i+1, err)
+				}
+				g.release(s.result)
+			}
+		})
+	}
+}
+
+func TestGuardTrialIsExclusive(t *testing.T) {
+	g := newGuard(ExecutionPolicy{BreakerThreshold: 1, BreakerCooldown: time.Hour})
+	if err := g.acquire("Add"); err != nil {
+		t.Fatal(err)
+	}
+	g.release(evaluation.ErrFunctionPanic)
+
+	g.openedAt = g.openedAt.Add(-time.Hour)
+	if err := g.acquire("Add"); err != nil {
+		t.Fatalf("trial call: %v", err)
+	}
+
+	err := g.acquire("Add")
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("acquire during trial = %v, want %v", err, ErrCircuitOpen)
+	}
+	if limitErr.RetryAfter != 0 {
+		t.Errorf("RetryAfter during trial = %v, want 0", limitErr.RetryAfter)
+	}
+}
+
+func TestBaseName(t *testing.T) {
+	tests := map[string]string{
+		"Add":                  "Add",
+		"Max[int]":             "Max",
+		"Pair[string,float64]": "Pair",
+	}
+	for name, want := range tests {
+		if got := BaseName(name); got != want {
+			t.Errorf("BaseName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"go-agent/metadata"
 	"go-agent/tools/evaluation"
 	"log/slog"
+	"strings"
+	"sync"
 )
 
 var (
@@ -16,8 +18,15 @@ var (
 
 // ToolStore is a thread-safe collection of tools indexed by their names.
 type ToolStore struct {
-	tools  map[string]evaluation.Tool
-	logger *slog.Logger
+	tools    map[string]evaluation.Tool
+	packages []metadata.PackageMetaData
+	logger   *slog.Logger
+
+	mu            sync.Mutex // Guards the execution policies, their state and the cache
+	policies      map[string]ExecutionPolicy
+	defaultPolicy ExecutionPolicy
+	guards        map[string]*guard
+	cache         *evaluation.Cache
 }
 
 // NewToolStore creates a new ToolStore with an optional logger.
@@ -35,13 +44,24 @@ func NewToolStore(logger *slog.Logger) *ToolStore {
 func NewFunctionStoreFromPkg(importPath string, funcMap map[string]interface{}, logger *slog.Logger) (*ToolStore, error) {
 	store := NewToolStore(logger)
 
+	pkg, err := metadata.ExtractPackageMetadata(importPath)
+	if err != nil {
+		store.logger.Warn("Failed to read package documentation", "package", importPath, "error", err)
+	} else {
+		store.packages = append(store.packages, pkg)
+	}
+
 	for functionName, function := range funcMap {
 		metadata, err := metadata.ExtractMetadata(importPath, functionName)
 		if err != nil {
-			logger.Error("Failed to extract metadata", "function", functionName, "error", err)
+			store.logger.Error("Failed to extract metadata", "function", functionName, "error", err)
 			return nil, fmt.Errorf("%w: %v", ErrMetadataExtraction, err)
 		}
 
+		for _, d := range metadata.Diagnostics {
+			store.logger.Warn("Documentation problem", "function", functionName, "diagnostic", d.String())
+		}
+
 		store.AddTool(functionName, evaluation.Tool{
 			Metadata: metadata,
 			Function: function,
@@ -51,28 +71,70 @@ func NewFunctionStoreFromPkg(importPath string, funcMap map[string]interface{},
 	return store, nil
 }
 
-// AddTool adds a new tool to the ToolStore.
+// AddTool adds a new tool to the ToolStore. A Function given as a map from
+// type arguments to instantiations is registered as evaluation.Instantiations.
 func (ts *ToolStore) AddTool(name string, tool evaluation.Tool) error {
 	if _, exists := ts.tools[name]; exists {
 		ts.logger.Error("Tool already exists", "name", name)
 		return ErrToolExists
 	}
 
+	if instantiations, ok := tool.Function.(map[string]interface{}); ok {
+		tool.Function = evaluation.Instantiations(instantiations)
+	}
+
 	ts.tools[name] = tool
+	ts.invalidate(name)
 	ts.logger.Info("Tool added", "name", name)
 	return nil
 }
 
-// GetTool retrieves a tool from the ToolStore by name.
+// GetTool retrieves a tool from the ToolStore by name. A generic tool may be
+// requested with explicit type arguments, e.g. "Max[int]", in which case the
+// returned tool is bound to that instantiation.
 func (ts *ToolStore) GetTool(name string) (evaluation.Tool, error) {
-	tool, exists := ts.tools[name]
+	baseName, typeArgs, generic := splitTypeArgs(name)
+
+	tool, exists := ts.tools[baseName]
 	if !exists {
 		ts.logger.Error("Tool not found", "name", name)
 		return evaluation.Tool{}, ErrToolNotFound
 	}
+
+	if generic {
+		instantiations, ok := tool.Function.(evaluation.Instantiations)
+		if !ok {
+			ts.logger.Error("Tool is not generic", "name", name)
+			return evaluation.Tool{}, fmt.Errorf("%w: %s is not generic", ErrToolNotFound, baseName)
+		}
+
+		function, err := instantiations.Instantiate(typeArgs)
+		if err != nil {
+			ts.logger.Error("Instantiation not found", "name", name)
+			return evaluation.Tool{}, fmt.Errorf("%w: %v", ErrToolNotFound, err)
+		}
+		tool.Function = function
+	}
+
 	return tool, nil
 }
 
+// BaseName returns the name of a tool without its type arguments, e.g. "Max"
+// for "Max[int]", which GetTool resolves to the same generic tool.
+func BaseName(name string) string {
+	baseName, _, _ := splitTypeArgs(name)
+	return baseName
+}
+
+// splitTypeArgs splits "Max[int]" into "Max" and "int".
+func splitTypeArgs(name string) (string, string, bool) {
+	open := strings.IndexByte(name, '[')
+	if open < 0 || !strings.HasSuffix(name, "]") {
+		return name, "", false
+	}
+	return name[:open], name[open+1 : len(name)-1], true
+}
+
 // RemoveTool removes a tool from the ToolStore by name.
 func (ts *ToolStore) RemoveTool(name string) error {
 
@@ -82,10 +144,33 @@ func (ts *ToolStore) RemoveTool(name string) error {
 	}
 
 	delete(ts.tools, name)
+	ts.invalidate(name)
 	ts.logger.Info("Tool removed", "name", name)
 	return nil
 }
 
+// SetCache sets the cache used for the results of pure tools by the Executor
+// of the store; nil disables caching. Cached results of a tool are dropped
+// when the tool is added or removed.
+func (ts *ToolStore) SetCache(cache *evaluation.Cache) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.cache = cache
+}
+
+// Cache returns the cache set with SetCache, if any.
+func (ts *ToolStore) Cache() *evaluation.Cache {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	return ts.cache
+}
+
+func (ts *ToolStore) invalidate(name string) {
+	if cache := ts.Cache(); cache != nil {
+		cache.Invalidate(name)
+	}
+}
+
 // ListTools returns a list of all tool names in the ToolStore.
 func (ts *ToolStore) ListToolNames() []string {
 
@@ -100,3 +185,8 @@ func (ts *ToolStore) ListToolNames() []string {
 func (ts *ToolStore) Tools() map[string]evaluation.Tool {
 	return ts.tools
 }
+
+// Packages returns the documentation of the packages the tools were loaded from.
+func (ts *ToolStore) Packages() []metadata.PackageMetaData {
+	return ts.packages
+}